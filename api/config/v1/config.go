@@ -62,6 +62,9 @@ func NewConfig(c *cli.Context, flags []cli.Flag) (*Config, error) {
 	if c.IsSet("imex-required") {
 		config.Imex.Required = c.Bool("imex-required")
 	}
+	if c.IsSet("imex-channel-pool") {
+		config.Imex.Pool = c.IntSlice("imex-channel-pool")
+	}
 
 	// If nvidiaDevRoot (the path to the device nodes on the host) is not set,
 	// we default to using the driver root on the host.
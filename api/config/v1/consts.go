@@ -49,9 +49,29 @@ const (
 	DeviceIDStrategyIndex = "index"
 )
 
+// Constants representing the ways the vGPU memory resource is advertised
+const (
+	MemoryAdvertisementDevices    = "devices"
+	MemoryAdvertisementNodeStatus = "node-status"
+)
+
+// Constants representing how the limits of vGPU containers are enforced
+const (
+	VGPUIsolationLibvgpu = "libvgpu"
+	VGPUIsolationMPS     = "mps"
+)
+
 // Constants related to generating CDI specifications
 const (
 	DefaultCDIAnnotationPrefix = cdiapi.AnnotationPrefix
 	DefaultNvidiaCTKPath       = "/usr/bin/nvidia-ctk"
 	DefaultContainerDriverRoot = "/driver-root"
 )
+
+// Constants related to injecting libvgpu
+const (
+	DefaultHookPath      = "/usr/local/vgpu"
+	DefaultLibvgpuPath   = "/usr/local/vgpu/libvgpu.so"
+	DefaultVGPUCachePath = "/tmp/vgpu"
+	DefaultVGPULockPath  = "/tmp/vgpulock"
+)
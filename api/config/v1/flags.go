@@ -79,6 +79,24 @@ type PluginCommandLineFlags struct {
 	CDIAnnotationPrefix *string                 `json:"cdiAnnotationPrefix" yaml:"cdiAnnotationPrefix"`
 	NvidiaCTKPath       *string                 `json:"nvidiaCTKPath"       yaml:"nvidiaCTKPath"`
 	ContainerDriverRoot *string                 `json:"containerDriverRoot" yaml:"containerDriverRoot"`
+	HookPath            *string                 `json:"hookPath"            yaml:"hookPath"`
+	LibvgpuPath         *string                 `json:"libvgpuPath"         yaml:"libvgpuPath"`
+	VGPUCachePath       *string                 `json:"vgpuCachePath"       yaml:"vgpuCachePath"`
+	VGPULockPath        *string                 `json:"vgpuLockPath"        yaml:"vgpuLockPath"`
+	MemoryAdvertisement *string                 `json:"memoryAdvertisement" yaml:"memoryAdvertisement"`
+	VGPUIsolation       *string                 `json:"vgpuIsolation"       yaml:"vgpuIsolation"`
+}
+
+// MemoryInNodeStatus reports whether the vGPU memory resource is advertised
+// through the node status instead of by a device plugin.
+func (f *PluginCommandLineFlags) MemoryInNodeStatus() bool {
+	return f != nil && f.MemoryAdvertisement != nil && *f.MemoryAdvertisement == MemoryAdvertisementNodeStatus
+}
+
+// VGPUIsolatedByMPS reports whether vGPU containers are limited as clients of
+// an MPS daemon instead of by libvgpu.
+func (f *PluginCommandLineFlags) VGPUIsolatedByMPS() bool {
+	return f != nil && f.VGPUIsolation != nil && *f.VGPUIsolation == VGPUIsolationMPS
 }
 
 // deviceListStrategyFlag is a custom type for parsing the deviceListStrategy flag.
@@ -157,6 +175,18 @@ func (f *Flags) UpdateFromCLIFlags(c *cli.Context, flags []cli.Flag) {
 				updateFromCLIFlag(&f.Plugin.NvidiaCTKPath, c, n)
 			case "container-driver-root":
 				updateFromCLIFlag(&f.Plugin.ContainerDriverRoot, c, n)
+			case "hook-path":
+				updateFromCLIFlag(&f.Plugin.HookPath, c, n)
+			case "libvgpu-path":
+				updateFromCLIFlag(&f.Plugin.LibvgpuPath, c, n)
+			case "vgpu-cache-path":
+				updateFromCLIFlag(&f.Plugin.VGPUCachePath, c, n)
+			case "vgpu-lock-path":
+				updateFromCLIFlag(&f.Plugin.VGPULockPath, c, n)
+			case "memory-advertisement":
+				updateFromCLIFlag(&f.Plugin.MemoryAdvertisement, c, n)
+			case "vgpu-isolation":
+				updateFromCLIFlag(&f.Plugin.VGPUIsolation, c, n)
 			}
 			// GFD specific flags
 			if f.GFD == nil {
@@ -23,6 +23,9 @@ import (
 
 const (
 	ImexChannelEnvVar = "NVIDIA_IMEX_CHANNELS"
+
+	// maxImexChannels is the number of IMEX channels the driver supports.
+	maxImexChannels = 2048
 )
 
 var errInvalidImexConfig = errors.New("invalid IMEX config")
@@ -39,6 +42,10 @@ type Imex struct {
 	// If it is not required its injection is skipped if the device nodes do not exist or if its
 	// existence cannot be queried.
 	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
+	// Pool defines a list of channel IDs allocated one per pod to the pods asking for one.
+	// Such a pod gets its channel instead of the ChannelIDs, so that its job is isolated from
+	// the other jobs on the node. Required applies to these channels too.
+	Pool []int `json:"pool,omitempty" yaml:"pool,omitempty"`
 }
 
 // AssertChannelIDsIsValid checks whether the specified list of channel IDs is valid.
@@ -51,3 +58,19 @@ func AssertChannelIDsValid(ids []int) error {
 	}
 	return fmt.Errorf("%w: channelIDs must be [] or [0]; found %v", errInvalidImexConfig, ids)
 }
+
+// AssertChannelPoolValid checks whether the specified pool of channel IDs is
+// valid: distinct channels other than 0, which is shared by all containers.
+func AssertChannelPoolValid(ids []int) error {
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if id <= 0 || id >= maxImexChannels {
+			return fmt.Errorf("%w: pool channel IDs must be between 1 and %d; found %d", errInvalidImexConfig, maxImexChannels-1, id)
+		}
+		if seen[id] {
+			return fmt.Errorf("%w: pool channel ID %d is repeated", errInvalidImexConfig, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
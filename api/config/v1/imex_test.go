@@ -70,13 +70,45 @@ func TestImexUnmarshal(t *testing.T) {
 			},
 			expectedError: errInvalidImexConfig,
 		},
+		{
+			description: "channel pool is valid",
+			input:       `{"channelIDs": [0], "pool": [1, 2, 3]}`,
+			expected: Imex{
+				ChannelIDs: []int{0},
+				Pool:       []int{1, 2, 3},
+			},
+		},
+		{
+			description: "channel 0 in the pool is invalid",
+			input:       `{"pool": [0, 1]}`,
+			expected: Imex{
+				Pool: []int{0, 1},
+			},
+			expectedError: errInvalidImexConfig,
+		},
+		{
+			description: "repeated pool channel is invalid",
+			input:       `{"pool": [1, 1]}`,
+			expected: Imex{
+				Pool: []int{1, 1},
+			},
+			expectedError: errInvalidImexConfig,
+		},
+		{
+			description: "pool channel out of range is invalid",
+			input:       `{"pool": [2048]}`,
+			expected: Imex{
+				Pool: []int{2048},
+			},
+			expectedError: errInvalidImexConfig,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
 			var output Imex
 			err := json.Unmarshal([]byte(tc.input), &output)
-			require.ErrorIs(t, errors.Join(err, AssertChannelIDsValid(output.ChannelIDs)), tc.expectedError)
+			require.ErrorIs(t, errors.Join(err, AssertChannelIDsValid(output.ChannelIDs), AssertChannelPoolValid(output.Pool)), tc.expectedError)
 			require.Equal(t, tc.expected, output)
 		})
 	}
@@ -0,0 +1,352 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	nvinfo "github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+	"k8s.io/klog/v2"
+
+	spec "volcano.sh/k8s-device-plugin/api/config/v1"
+	"volcano.sh/k8s-device-plugin/pkg/flags"
+	"volcano.sh/k8s-device-plugin/pkg/info"
+	"volcano.sh/k8s-device-plugin/pkg/lm"
+	"volcano.sh/k8s-device-plugin/pkg/resource"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+	"volcano.sh/k8s-device-plugin/pkg/vgpu"
+	"volcano.sh/k8s-device-plugin/pkg/watch"
+)
+
+type options struct {
+	flags            []cli.Flag
+	kubeClientConfig flags.KubeClientConfig
+	nodeConfig       flags.NodeConfig
+}
+
+func main() {
+	c := cli.NewApp()
+	o := &options{}
+	c.Name = "vGPU Feature Discovery"
+	c.Usage = "generate labels for the GPUs and vGPUs of the node"
+	c.Version = info.GetVersionString()
+	c.Action = func(ctx *cli.Context) error {
+		return start(ctx, o)
+	}
+
+	c.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mig-strategy",
+			Value:   spec.MigStrategyNone,
+			Usage:   "the desired strategy for exposing MIG devices on GPUs that support it:\n\t\t[none | single | mixed]",
+			EnvVars: []string{"MIG_STRATEGY"},
+		},
+		&cli.BoolFlag{
+			Name:    "fail-on-init-error",
+			Value:   true,
+			Usage:   "fail if an error is encountered during initialization, otherwise label the node as having no GPUs",
+			EnvVars: []string{"FAIL_ON_INIT_ERROR"},
+		},
+		&cli.StringFlag{
+			Name:    "driver-root",
+			Aliases: []string{"nvidia-driver-root"},
+			Value:   "/",
+			Usage:   "the root path for the NVIDIA driver installation on the host (typical values are '/' or '/run/nvidia/driver')",
+			EnvVars: []string{"NVIDIA_DRIVER_ROOT"},
+		},
+		&cli.StringFlag{
+			Name:    "device-discovery-strategy",
+			Value:   "auto",
+			Usage:   "the strategy to use to discover devices: 'auto', 'nvml', or 'tegra'",
+			EnvVars: []string{"DEVICE_DISCOVERY_STRATEGY"},
+		},
+		&cli.BoolFlag{
+			Name:    "oneshot",
+			Value:   false,
+			Usage:   "label once and exit",
+			EnvVars: []string{"GFD_ONESHOT"},
+		},
+		&cli.BoolFlag{
+			Name:    "no-timestamp",
+			Value:   false,
+			Usage:   "do not add the timestamp to the labels",
+			EnvVars: []string{"GFD_NO_TIMESTAMP"},
+		},
+		&cli.GenericFlag{
+			Name:    "sleep-interval",
+			Value:   spec.NewDurationValue(60 * time.Second),
+			Usage:   "time to sleep between labeling",
+			EnvVars: []string{"GFD_SLEEP_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name:    "output-file",
+			Aliases: []string{"output", "o"},
+			Value:   "/etc/kubernetes/node-feature-discovery/features.d/vgpu",
+			Usage:   "the file the labels are written to; they are written to stdout when empty",
+			EnvVars: []string{"GFD_OUTPUT_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "machine-type-file",
+			Value:   "/sys/class/dmi/id/product_name",
+			Usage:   "a file containing the machine type of the node",
+			EnvVars: []string{"GFD_MACHINE_TYPE_FILE"},
+		},
+		&cli.BoolFlag{
+			Name:    "use-node-feature-api",
+			Usage:   "write the labels to a NodeFeature object of the node instead of the output file",
+			EnvVars: []string{"GFD_USE_NODE_FEATURE_API"},
+		},
+		&cli.StringFlag{
+			Name:    "config-file",
+			Usage:   "the path to a config file as an alternative to command line options or environment variables",
+			EnvVars: []string{"CONFIG_FILE"},
+		},
+		// The following CLI flags override the device config, as they do for
+		// the device plugin.
+		&cli.UintFlag{
+			Name:  "device-split-count",
+			Usage: "the number for NVIDIA device split",
+			Value: 2,
+		},
+		&cli.UintFlag{
+			Name:  "gpu-memory-factor",
+			Usage: "the default gpu memory block size is 1MB",
+			Value: 1,
+		},
+		&cli.Float64Flag{
+			Name:  "device-cores-scaling",
+			Usage: "the ratio for NVIDIA device cores scaling",
+			Value: 1.0,
+		},
+	}
+	c.Flags = append(c.Flags, o.kubeClientConfig.Flags()...)
+	c.Flags = append(c.Flags, o.nodeConfig.Flags()...)
+	o.flags = c.Flags
+
+	err := c.Run(os.Args)
+	if err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+}
+
+func validateFlags(config *spec.Config) error {
+	switch *config.Flags.MigStrategy {
+	case spec.MigStrategyNone:
+	case spec.MigStrategySingle:
+	case spec.MigStrategyMixed:
+	default:
+		return fmt.Errorf("unknown MIG strategy: %v", *config.Flags.MigStrategy)
+	}
+
+	switch *config.Flags.DeviceDiscoveryStrategy {
+	case "auto":
+	case "nvml":
+	case "tegra":
+	default:
+		return fmt.Errorf("invalid --device-discovery-strategy option %v", *config.Flags.DeviceDiscoveryStrategy)
+	}
+
+	if config.Flags.GFD.SleepInterval == nil || time.Duration(*config.Flags.GFD.SleepInterval) <= 0 {
+		return fmt.Errorf("invalid --sleep-interval option: must be positive")
+	}
+
+	return nil
+}
+
+func loadConfig(c *cli.Context, flags []cli.Flag) (*spec.Config, error) {
+	config, err := spec.NewConfig(c, flags)
+	if err != nil {
+		return nil, fmt.Errorf("unable to finalize config: %v", err)
+	}
+	if err := validateFlags(config); err != nil {
+		return nil, fmt.Errorf("unable to validate flags: %v", err)
+	}
+	config.Flags.Plugin = nil
+	return config, nil
+}
+
+func start(c *cli.Context, o *options) error {
+	klog.InfoS(fmt.Sprintf("Starting %s", c.App.Name), "version", c.App.Version)
+	defer klog.Info("Exiting")
+
+	klog.Info("Starting OS watcher.")
+	sigs := watch.Signals(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	for {
+		klog.Info("Loading configuration.")
+		config, err := loadConfig(c, o.flags)
+		if err != nil {
+			return fmt.Errorf("unable to load config: %v", err)
+		}
+		spec.DisableResourceNamingInConfig(config)
+
+		// The vGPU labels describe the device config the device plugin runs
+		// with, which is loaded the same way.
+		util.LoadNvidiaConfig(c)
+
+		configJSON, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal config to JSON: %v", err)
+		}
+		klog.Infof("\nRunning with config:\n%v", string(configJSON))
+
+		nvmllib := nvml.New()
+		devicelib := device.New(nvmllib)
+		infolib := nvinfo.New(
+			nvinfo.WithRoot(*config.Flags.NvidiaDriverRoot),
+			nvinfo.WithNvmlLib(nvmllib),
+			nvinfo.WithDeviceLib(devicelib),
+		)
+		manager, err := resource.NewManager(infolib, nvmllib, devicelib, config)
+		if err != nil {
+			return fmt.Errorf("failed to create resource manager: %v", err)
+		}
+
+		// The clients are only required to output NodeFeature objects; the
+		// vGPU labeler, wired here rather than in lm.NewLabelers because it
+		// needs them, leaves the free memory out without them.
+		clientSets, err := o.kubeClientConfig.NewClientSets()
+		if err != nil {
+			if *config.Flags.UseNodeFeatureAPI {
+				return fmt.Errorf("failed to create clientsets: %v", err)
+			}
+			klog.Warningf("Unable to create clientsets, not labeling the free vGPU memory: %v", err)
+		}
+		outputer, err := lm.NewOutputer(config, o.nodeConfig, clientSets)
+		if err != nil {
+			return fmt.Errorf("failed to create outputer: %v", err)
+		}
+
+		d := featureDiscovery{
+			manager:     manager,
+			vgpu:        vgpu.NewVGPULib(vgpu.NewNvidiaPCILib()),
+			vgpuLabeler: lm.NewVolcanoVGPULabeler(clientSets.Core, o.nodeConfig.Name),
+			config:      config,
+			outputer:    outputer,
+		}
+		restart, err := d.run(sigs)
+		if err != nil || !restart {
+			return err
+		}
+	}
+}
+
+type featureDiscovery struct {
+	manager     resource.Manager
+	vgpu        vgpu.Interface
+	vgpuLabeler lm.Labeler
+	config      *spec.Config
+	outputer    lm.Outputer
+}
+
+// run labels the node every sleep interval until a signal is received, and
+// reports whether the labeling should be restarted with a reloaded config.
+func (d *featureDiscovery) run(sigs chan os.Signal) (bool, error) {
+	defer func() {
+		if *d.config.Flags.UseNodeFeatureAPI || *d.config.Flags.GFD.Oneshot || *d.config.Flags.GFD.OutputFile == "" {
+			return
+		}
+		if err := removeOutputFile(*d.config.Flags.GFD.OutputFile); err != nil {
+			klog.Warningf("Error removing output file: %v", err)
+		}
+	}()
+
+	timestampLabeler := lm.NewTimestampLabeler(d.config)
+rerun:
+	labelers, err := lm.NewLabelers(d.manager, d.vgpu, d.config)
+	if err != nil {
+		return false, err
+	}
+
+	labels, err := lm.Merge(timestampLabeler, labelers, d.vgpuLabeler).Labels()
+	if err != nil {
+		return false, fmt.Errorf("error generating labels: %v", err)
+	}
+	if len(labels) <= 1 {
+		klog.Warning("No labels generated from any source")
+	}
+
+	klog.Info("Creating labels")
+	if err := d.outputer.Output(labels); err != nil {
+		return false, err
+	}
+
+	if *d.config.Flags.GFD.Oneshot {
+		return false, nil
+	}
+
+	klog.Info("Sleeping for ", *d.config.Flags.GFD.SleepInterval)
+	rerunTimeout := time.After(time.Duration(*d.config.Flags.GFD.SleepInterval))
+
+	for {
+		select {
+		case <-rerunTimeout:
+			goto rerun
+
+		// On SIGHUP, reload the config and label again. On all other
+		// signals, exit.
+		case s := <-sigs:
+			switch s {
+			case syscall.SIGHUP:
+				klog.Info("Received SIGHUP, restarting.")
+				return true, nil
+			default:
+				klog.Infof("Received signal \"%v\", shutting down.", s)
+				return false, nil
+			}
+		}
+	}
+}
+
+// removeOutputFile removes the labels written to the output file, so that
+// they do not outlive the process.
+func removeOutputFile(path string) error {
+	absOutputFile, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve absolute path of output file: %v", err)
+	}
+
+	absTmpDir, err := filepath.Abs(filepath.Dir(absOutputFile))
+	if err != nil {
+		return fmt.Errorf("failed to retrieve absolute path of output directory: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(absTmpDir, "vfd-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	// Remove the file through a rename, which is atomic, so that NFD never
+	// reads it partially.
+	tmpFile := filepath.Join(tmpDir, "vfd-tmp")
+	if err := os.Rename(absOutputFile, tmpFile); err != nil {
+		return fmt.Errorf("failed to move output file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,517 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+
+	"github.com/google/renameio"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// UsageAnnotation is set on a terminated pod to the total vGPU usage of
+	// its containers, as a JSON encoded UsageSummary.
+	UsageAnnotation = "volcano.sh/vgpu-usage"
+
+	accountingFile       = "accounting.jsonl"
+	accountingCheckpoint = "checkpoint.json"
+
+	accountingSampleInterval = 10 * time.Second
+	defaultAccountingPeriod  = 5 * time.Minute
+	defaultAccountingMaxSize = 100 // MiB
+	defaultAccountingMaxFile = 5
+
+	mib = 1 << 20
+)
+
+// AccountingRecord is the usage of a container over one accounting period.
+// Summing the records of a container gives its total usage.
+type AccountingRecord struct {
+	Namespace string    `json:"namespace,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	PodUID    string    `json:"podUID"`
+	Container string    `json:"container"`
+	Devices   []string  `json:"devices,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	// MemoryMiBSeconds is the device memory used, integrated over time.
+	MemoryMiBSeconds float64 `json:"memoryMiBSeconds"`
+	// SMSeconds is the SM utilization integrated over time, one SM-second
+	// being a whole device busy for one second.
+	SMSeconds float64 `json:"smSeconds"`
+	// Final marks the last record of a container.
+	Final bool `json:"final,omitempty"`
+}
+
+// UsageSummary is the value of UsageAnnotation.
+type UsageSummary struct {
+	MemoryMiBSeconds float64 `json:"memoryMiBSeconds"`
+	SMSeconds        float64 `json:"smSeconds"`
+}
+
+// account is the usage accumulated for a container. It is checkpointed so
+// that a restarted monitor carries on with the current period.
+type account struct {
+	AccountingRecord
+	// Total is the usage of the periods already recorded.
+	Total UsageSummary `json:"total"`
+
+	lastSample time.Time
+}
+
+// accountingState is what the checkpoint holds: the accounts, and the records
+// closed out of them that may not be in the accounting file yet.
+type accountingState struct {
+	Accounts map[string]*account `json:"accounts"`
+	Pending  []AccountingRecord  `json:"pending,omitempty"`
+}
+
+// Accountant integrates the usage of every container and writes it out as
+// JSON lines accounting records.
+type Accountant struct {
+	// Period is how often a record is written for a running container.
+	Period time.Duration
+	// Annotate enables UsageAnnotation on terminated pods.
+	Annotate bool
+
+	dir       string
+	writer    *rotatingWriter
+	client    kubernetes.Interface
+	podLister listerscorev1.PodLister
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	accounts map[string]*account
+	// pending are the records to write, checkpointed with the accounts they
+	// were closed out of.
+	pending []AccountingRecord
+	// annotations are the usage annotations of the pods that terminated.
+	annotations []annotationUpdate
+}
+
+// NewAccountantFromEnv builds an accountant from the ACCOUNTING_*
+// environment variables. It returns nil when ACCOUNTING_DIR is not set, which
+// disables accounting.
+func NewAccountantFromEnv(lister *nvidia.ContainerLister) (*Accountant, error) {
+	dir := os.Getenv("ACCOUNTING_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+	period := defaultAccountingPeriod
+	if v := os.Getenv("ACCOUNTING_PERIOD"); v != "" {
+		var err error
+		period, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACCOUNTING_PERIOD %q: %w", v, err)
+		}
+		if period < accountingSampleInterval {
+			return nil, fmt.Errorf("ACCOUNTING_PERIOD must be at least %v, got %v", accountingSampleInterval, period)
+		}
+	}
+	maxSize, err := positiveIntFromEnv("ACCOUNTING_MAX_SIZE", defaultAccountingMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	maxFiles, err := positiveIntFromEnv("ACCOUNTING_MAX_FILES", defaultAccountingMaxFile)
+	if err != nil {
+		return nil, err
+	}
+	annotate := false
+	if v := os.Getenv("ACCOUNTING_ANNOTATE"); v != "" {
+		annotate, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACCOUNTING_ANNOTATE %q: %w", v, err)
+		}
+	}
+	a, err := newAccountant(dir, int64(maxSize)*mib, maxFiles, lister.Clientset(), lister.PodLister())
+	if err != nil {
+		return nil, err
+	}
+	a.Period = period
+	a.Annotate = annotate
+	return a, nil
+}
+
+func positiveIntFromEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", name, v)
+	}
+	return n, nil
+}
+
+func newAccountant(dir string, maxSize int64, maxFiles int, client kubernetes.Interface, podLister listerscorev1.PodLister) (*Accountant, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create accounting directory: %w", err)
+	}
+	writer, err := newRotatingWriter(filepath.Join(dir, accountingFile), maxSize, maxFiles)
+	if err != nil {
+		return nil, err
+	}
+	a := &Accountant{
+		Period:    defaultAccountingPeriod,
+		dir:       dir,
+		writer:    writer,
+		client:    client,
+		podLister: podLister,
+		now:       time.Now,
+		accounts:  make(map[string]*account),
+	}
+	if err := a.loadCheckpoint(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Accountant) loadCheckpoint() error {
+	data, err := os.ReadFile(filepath.Join(a.dir, accountingCheckpoint))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read accounting checkpoint: %w", err)
+	}
+	var state accountingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		// Starting over is better than not accounting at all.
+		klog.Errorf("Ignoring corrupted accounting checkpoint: %v", err)
+		return nil
+	}
+	if state.Accounts != nil {
+		a.accounts = state.Accounts
+	}
+	if len(state.Pending) > 0 {
+		// The monitor may have stopped after writing the pending records,
+		// but before checkpointing that it did.
+		written, err := a.written(state.Pending)
+		if err != nil {
+			return fmt.Errorf("failed to check pending accounting records: %w", err)
+		}
+		if !written {
+			a.pending = state.Pending
+		}
+	}
+	klog.Infof("Restored accounting of %d containers and %d pending records from checkpoint", len(a.accounts), len(a.pending))
+	return nil
+}
+
+func (a *Accountant) saveCheckpoint() error {
+	data, err := json.Marshal(accountingState{Accounts: a.accounts, Pending: a.pending})
+	if err != nil {
+		return err
+	}
+	return renameio.WriteFile(filepath.Join(a.dir, accountingCheckpoint), data, 0644)
+}
+
+// written reports whether records are the last ones of the accounting file.
+func (a *Accountant) written(records []AccountingRecord) (bool, error) {
+	data, err := encodeRecords(records)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open(filepath.Join(a.dir, accountingFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() < int64(len(data)) {
+		return false, nil
+	}
+	tail := make([]byte, len(data))
+	if _, err := f.ReadAt(tail, info.Size()-int64(len(data))); err != nil {
+		return false, err
+	}
+	return bytes.Equal(tail, data), nil
+}
+
+// Sample adds the usage of every container since the previous sample, and
+// closes the periods that ended and the accounts of the pods that terminated.
+// Their records and annotations are left to Flush, so that no I/O is done
+// while the containers are read.
+func (a *Accountant) Sample(containers map[string]*nvidia.ContainerUsage) {
+	pods, err := a.podLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list pods: %v", err)
+		return
+	}
+	podsByUID := make(map[string]*corev1.Pod, len(pods))
+	for _, pod := range pods {
+		podsByUID[string(pod.UID)] = pod
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	for key, c := range containers {
+		if c.Info == nil {
+			continue
+		}
+		acct, ok := a.accounts[key]
+		if !ok {
+			// Caches outlive their pod for a while: only start accounting
+			// for containers of running pods.
+			pod, ok := podsByUID[c.PodUID]
+			if !ok || podTerminated(pod) {
+				continue
+			}
+			acct = &account{AccountingRecord: AccountingRecord{
+				Namespace: pod.Namespace,
+				Pod:       pod.Name,
+				PodUID:    c.PodUID,
+				Container: c.ContainerName,
+				Start:     now,
+			}}
+			a.accounts[key] = acct
+		}
+		var memory, smUtil uint64
+		var devices []string
+		for i := 0; i < c.Info.DeviceNum(); i++ {
+			if !c.Info.IsValidUUID(i) {
+				continue
+			}
+			devices = append(devices, c.Info.DeviceUUID(i))
+			memory += c.Info.DeviceMemoryTotal(i)
+			smUtil += c.Info.DeviceSmUtil(i)
+		}
+		acct.Devices = devices
+		// The first sample after a container showed up, or after a restart,
+		// only sets the baseline: nothing is known about the time before it.
+		if !acct.lastSample.IsZero() {
+			dt := now.Sub(acct.lastSample).Seconds()
+			acct.MemoryMiBSeconds += float64(memory) / mib * dt
+			acct.SMSeconds += float64(smUtil) / 100 * dt
+		}
+		acct.lastSample = now
+	}
+
+	var records []AccountingRecord
+	finished := map[string]*UsageSummary{}
+	for key, acct := range a.accounts {
+		pod, ok := podsByUID[acct.PodUID]
+		if !ok || podTerminated(pod) {
+			records = append(records, acct.close(now, true))
+			delete(a.accounts, key)
+			if ok {
+				summary := finished[acct.PodUID]
+				if summary == nil {
+					summary = &UsageSummary{}
+					finished[acct.PodUID] = summary
+				}
+				summary.MemoryMiBSeconds += acct.Total.MemoryMiBSeconds
+				summary.SMSeconds += acct.Total.SMSeconds
+			}
+			continue
+		}
+		if now.Sub(acct.Start) >= a.Period {
+			// Periods without usage, e.g. while the monitor was down, are
+			// not worth a record.
+			if record := acct.close(now, false); record.MemoryMiBSeconds > 0 || record.SMSeconds > 0 {
+				records = append(records, record)
+			}
+		}
+	}
+
+	a.pending = append(a.pending, records...)
+	if a.Annotate {
+		for uid, summary := range finished {
+			if update, ok := usageAnnotation(podsByUID[uid], summary); ok {
+				a.annotations = append(a.annotations, update)
+			}
+		}
+	}
+}
+
+// Flush checkpoints the accounts, writes the pending records and annotates the
+// pods that terminated. The checkpoint is saved before the records are
+// written, so that a restarted monitor does not close the same periods again,
+// and the records stay pending until a write succeeds.
+func (a *Accountant) Flush() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.saveCheckpoint(); err != nil {
+		klog.Errorf("Failed to checkpoint accounting, keeping %d records pending: %v", len(a.pending), err)
+	} else if err := a.write(a.pending); err != nil {
+		klog.Errorf("Failed to write accounting records, keeping %d records pending: %v", len(a.pending), err)
+	} else {
+		a.pending = nil
+	}
+	patchPodAnnotations(a.client, a.annotations)
+	a.annotations = nil
+}
+
+// close ends the current period of the account and returns its record.
+func (acct *account) close(now time.Time, final bool) AccountingRecord {
+	record := acct.AccountingRecord
+	record.End = now
+	record.Final = final
+	acct.Total.MemoryMiBSeconds += acct.MemoryMiBSeconds
+	acct.Total.SMSeconds += acct.SMSeconds
+	acct.Start = now
+	acct.MemoryMiBSeconds = 0
+	acct.SMSeconds = 0
+	return record
+}
+
+func podTerminated(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp != nil ||
+		pod.Status.Phase == corev1.PodSucceeded ||
+		pod.Status.Phase == corev1.PodFailed
+}
+
+// write appends records to the accounting file in a single write, so that
+// they all end up in the same file.
+func (a *Accountant) write(records []AccountingRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	data, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	if err := a.writer.Write(data); err != nil {
+		return err
+	}
+	return a.writer.Sync()
+}
+
+func encodeRecords(records []AccountingRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// usageAnnotation returns the update setting UsageAnnotation on a pod that
+// does not have it yet.
+func usageAnnotation(pod *corev1.Pod, summary *UsageSummary) (annotationUpdate, bool) {
+	if _, ok := pod.Annotations[UsageAnnotation]; ok {
+		return annotationUpdate{}, false
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		klog.Errorf("Failed to encode usage of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return annotationUpdate{}, false
+	}
+	value := string(data)
+	return annotationUpdate{pod: pod, key: UsageAnnotation, value: &value}, true
+}
+
+func watchAccounting(lister *nvidia.ContainerLister, accountant *Accountant) {
+	for {
+		time.Sleep(accountingSampleInterval)
+		lister.ReadContainers(accountant.Sample)
+		accountant.Flush()
+	}
+}
+
+// rotatingWriter appends to a file, moving it aside to <path>.1, <path>.2, ...
+// once it grows past maxSize and keeping at most maxFiles of those.
+type rotatingWriter struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+func newRotatingWriter(path string, maxSize int64, maxFiles int) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", w.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) error {
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return err
+}
+
+func (w *rotatingWriter) Sync() error {
+	return w.file.Sync()
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	for i := w.maxFiles - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+)
+
+// meteredUsage reports a constant memory usage and SM utilization.
+type meteredUsage struct {
+	fakeUsage
+	memory uint64
+	smUtil uint64
+}
+
+func (m *meteredUsage) DeviceMemoryTotal(idx int) uint64 { return m.memory }
+func (m *meteredUsage) DeviceSmUtil(idx int) uint64      { return m.smUtil }
+
+func readRecords(t *testing.T, path string) []AccountingRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var records []AccountingRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record AccountingRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+// sample samples the containers and flushes, as watchAccounting does.
+func sample(a *Accountant, containers map[string]*nvidia.ContainerUsage) {
+	a.Sample(containers)
+	a.Flush()
+}
+
+func TestAccountantSample(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "team-a", UID: types.UID("uid1")},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	client := fake.NewSimpleClientset(pod)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(pod))
+	newTestAccountant := func() *Accountant {
+		a, err := newAccountant(dir, mib, 2, client, listerscorev1.NewPodLister(indexer))
+		require.NoError(t, err)
+		a.Period = time.Minute
+		a.Annotate = true
+		a.now = func() time.Time { return now }
+		return a
+	}
+
+	// 512MiB and half of the SMs of one device.
+	usage := &meteredUsage{fakeUsage: fakeUsage{uuids: []string{"GPU-0"}}, memory: 512 * mib, smUtil: 50}
+	containers := map[string]*nvidia.ContainerUsage{
+		"uid1_main": {PodUID: "uid1", ContainerName: "main", Info: usage},
+	}
+	a := newTestAccountant()
+	for i := 0; i < 7; i++ {
+		sample(a, containers)
+		now = now.Add(10 * time.Second)
+	}
+	records := readRecords(t, filepath.Join(dir, accountingFile))
+	require.Len(t, records, 1)
+	require.Equal(t, "team-a", records[0].Namespace)
+	require.Equal(t, "train", records[0].Pod)
+	require.Equal(t, []string{"GPU-0"}, records[0].Devices)
+	require.Equal(t, time.Minute, records[0].End.Sub(records[0].Start))
+	require.InDelta(t, 512*60, records[0].MemoryMiBSeconds, 1e-6)
+	require.InDelta(t, 30, records[0].SMSeconds, 1e-6)
+	require.False(t, records[0].Final)
+
+	// A restarted monitor carries on with the checkpointed period; the time
+	// it was down is not accounted for.
+	now = now.Add(time.Minute)
+	a = newTestAccountant()
+	sample(a, containers)
+	now = now.Add(10 * time.Second)
+	sample(a, containers)
+
+	pod = pod.DeepCopy()
+	pod.Status.Phase = corev1.PodSucceeded
+	require.NoError(t, indexer.Update(pod))
+	now = now.Add(10 * time.Second)
+	a.Sample(containers)
+	// Nothing is written nor annotated while the containers are read.
+	require.Len(t, readRecords(t, filepath.Join(dir, accountingFile)), 1)
+	updated, err := client.CoreV1().Pods("team-a").Get(t.Context(), "train", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, updated.Annotations, UsageAnnotation)
+	a.Flush()
+
+	records = readRecords(t, filepath.Join(dir, accountingFile))
+	require.Len(t, records, 2)
+	require.True(t, records[1].Final)
+	require.InDelta(t, 512*20, records[1].MemoryMiBSeconds, 1e-6)
+	require.InDelta(t, 10, records[1].SMSeconds, 1e-6)
+
+	updated, err = client.CoreV1().Pods("team-a").Get(t.Context(), "train", metav1.GetOptions{})
+	require.NoError(t, err)
+	var summary UsageSummary
+	require.NoError(t, json.Unmarshal([]byte(updated.Annotations[UsageAnnotation]), &summary))
+	require.InDelta(t, 512*80, summary.MemoryMiBSeconds, 1e-6)
+	require.InDelta(t, 40, summary.SMSeconds, 1e-6)
+
+	// The cache of a terminated pod is not accounted for again.
+	now = now.Add(10 * time.Second)
+	sample(a, containers)
+	require.Len(t, readRecords(t, filepath.Join(dir, accountingFile)), 2)
+}
+
+func TestAccountantPendingRecords(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "team-a", UID: types.UID("uid1")},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(pod))
+	newTestAccountant := func() *Accountant {
+		a, err := newAccountant(dir, mib, 2, fake.NewSimpleClientset(pod), listerscorev1.NewPodLister(indexer))
+		require.NoError(t, err)
+		a.Period = time.Minute
+		a.now = func() time.Time { return now }
+		return a
+	}
+
+	usage := &meteredUsage{fakeUsage: fakeUsage{uuids: []string{"GPU-0"}}, memory: 512 * mib, smUtil: 50}
+	containers := map[string]*nvidia.ContainerUsage{
+		"uid1_main": {PodUID: "uid1", ContainerName: "main", Info: usage},
+	}
+	a := newTestAccountant()
+	for i := 0; i < 6; i++ {
+		sample(a, containers)
+		now = now.Add(10 * time.Second)
+	}
+	// The record of the first period can not be written: it is kept
+	// pending, and written by the restarted monitor.
+	require.NoError(t, a.writer.file.Close())
+	sample(a, containers)
+	require.Len(t, a.pending, 1)
+	require.Empty(t, readRecords(t, filepath.Join(dir, accountingFile)))
+
+	a = newTestAccountant()
+	require.Len(t, a.pending, 1)
+	now = now.Add(10 * time.Second)
+	sample(a, containers)
+	require.Empty(t, a.pending)
+	records := readRecords(t, filepath.Join(dir, accountingFile))
+	require.Len(t, records, 1)
+	require.InDelta(t, 512*60, records[0].MemoryMiBSeconds, 1e-6)
+
+	// The checkpoint still lists the record written last: a monitor
+	// restarted now finds it in the file rather than writing it again.
+	a = newTestAccountant()
+	require.Empty(t, a.pending)
+	now = now.Add(10 * time.Second)
+	sample(a, containers)
+	require.Len(t, readRecords(t, filepath.Join(dir, accountingFile)), 1)
+}
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), accountingFile)
+	w, err := newRotatingWriter(path, 10, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		require.NoError(t, w.Write([]byte(line)))
+	}
+
+	for suffix, expected := range map[string]string{"": "fourth\n", ".1": "third\n", ".2": "second\n"} {
+		data, err := os.ReadFile(path + suffix)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(data))
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+}
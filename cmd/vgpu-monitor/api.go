@@ -0,0 +1,232 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// DeviceStatus is the usage of one device by a container.
+type DeviceStatus struct {
+	Index       int    `json:"index"`
+	UUID        string `json:"uuid"`
+	MemoryUsed  uint64 `json:"memoryUsed"`
+	MemoryLimit uint64 `json:"memoryLimit"`
+	SMUtil      uint64 `json:"smUtil"`
+	// SMLimit is the core limit in percent, 0 when not limited.
+	SMLimit uint64 `json:"smLimit"`
+}
+
+// ContainerStatus is what the monitor knows about a container from its
+// shared region.
+type ContainerStatus struct {
+	Namespace string         `json:"namespace,omitempty"`
+	Pod       string         `json:"pod,omitempty"`
+	PodUID    string         `json:"podUID"`
+	Container string         `json:"container"`
+	Devices   []DeviceStatus `json:"devices"`
+	Priority  int            `json:"priority"`
+	// UtilizationSwitch is 1 while the core limit of the container is
+	// enforced.
+	UtilizationSwitch int32 `json:"utilizationSwitch"`
+	// Blocked is set while the container is kept from launching kernels by
+	// a container of higher priority.
+	Blocked        bool  `json:"blocked"`
+	LastKernelTime int64 `json:"lastKernelTime,omitempty"`
+}
+
+// PodStatus groups the containers of a pod.
+type PodStatus struct {
+	Namespace  string            `json:"namespace"`
+	Pod        string            `json:"pod"`
+	PodUID     string            `json:"podUID"`
+	Containers []ContainerStatus `json:"containers"`
+}
+
+// APIServer serves the state of the monitored containers as JSON, along with
+// the health of the monitor.
+type APIServer struct {
+	containers func(func(map[string]*nvidia.ContainerUsage))
+	client     kubernetes.Interface
+	podLister  listerscorev1.PodLister
+	nvmlCheck  func() error
+}
+
+// NewAPIServer returns an API server for the containers of lister.
+func NewAPIServer(lister *nvidia.ContainerLister) *APIServer {
+	return newAPIServer(lister.ReadContainers, lister.Clientset(), lister.PodLister(), checkNvml)
+}
+
+func newAPIServer(containers func(func(map[string]*nvidia.ContainerUsage)), client kubernetes.Interface, podLister listerscorev1.PodLister, nvmlCheck func() error) *APIServer {
+	return &APIServer{
+		containers: containers,
+		client:     client,
+		podLister:  podLister,
+		nvmlCheck:  nvmlCheck,
+	}
+}
+
+func checkNvml() error {
+	if _, ret := config.Nvml().DeviceGetCount(); ret != nvml.SUCCESS {
+		return fmt.Errorf("nvml unavailable: %v", ret)
+	}
+	return nil
+}
+
+// Register adds the API endpoints to mux:
+//
+//	GET /api/v1/containers                  all monitored containers
+//	GET /api/v1/pods/{namespace}/{name}     the containers of a single pod
+//	GET /healthz                            NVML is reachable
+//	GET /readyz                             NVML and the API server are reachable
+func (s *APIServer) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/containers", s.listContainers)
+	mux.HandleFunc("GET /api/v1/pods/{namespace}/{name}", s.getPod)
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+}
+
+func (s *APIServer) listContainers(w http.ResponseWriter, r *http.Request) {
+	pods, err := s.podLister.List(labels.Everything())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	podsByUID := make(map[string]*corev1.Pod, len(pods))
+	for _, pod := range pods {
+		podsByUID[string(pod.UID)] = pod
+	}
+	res := []ContainerStatus{}
+	s.containers(func(containers map[string]*nvidia.ContainerUsage) {
+		for _, c := range containers {
+			if c.Info == nil {
+				continue
+			}
+			res = append(res, containerStatus(c, podsByUID[c.PodUID]))
+		}
+	})
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].PodUID != res[j].PodUID {
+			return res[i].PodUID < res[j].PodUID
+		}
+		return res[i].Container < res[j].Container
+	})
+	writeJSON(w, res)
+}
+
+func (s *APIServer) getPod(w http.ResponseWriter, r *http.Request) {
+	pod, err := s.podLister.Pods(r.PathValue("namespace")).Get(r.PathValue("name"))
+	if errors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res := PodStatus{
+		Namespace:  pod.Namespace,
+		Pod:        pod.Name,
+		PodUID:     string(pod.UID),
+		Containers: []ContainerStatus{},
+	}
+	s.containers(func(containers map[string]*nvidia.ContainerUsage) {
+		for _, c := range containers {
+			if c.Info == nil || c.PodUID != string(pod.UID) {
+				continue
+			}
+			res.Containers = append(res.Containers, containerStatus(c, pod))
+		}
+	})
+	sort.Slice(res.Containers, func(i, j int) bool {
+		return res.Containers[i].Container < res.Containers[j].Container
+	})
+	writeJSON(w, res)
+}
+
+func containerStatus(c *nvidia.ContainerUsage, pod *corev1.Pod) ContainerStatus {
+	res := ContainerStatus{
+		PodUID:            c.PodUID,
+		Container:         c.ContainerName,
+		Devices:           []DeviceStatus{},
+		Priority:          c.Info.GetPriority(),
+		UtilizationSwitch: c.Info.GetUtilizationSwitch(),
+		Blocked:           c.Info.GetRecentKernel() < 0,
+		LastKernelTime:    c.Info.LastKernelTime(),
+	}
+	if pod != nil {
+		res.Namespace = pod.Namespace
+		res.Pod = pod.Name
+	}
+	for i := 0; i < c.Info.DeviceNum(); i++ {
+		if !c.Info.IsValidUUID(i) {
+			continue
+		}
+		res.Devices = append(res.Devices, DeviceStatus{
+			Index:       i,
+			UUID:        deviceUUID(c.Info, i),
+			MemoryUsed:  c.Info.DeviceMemoryTotal(i),
+			MemoryLimit: c.Info.DeviceMemoryLimit(i),
+			SMUtil:      c.Info.DeviceSmUtil(i),
+			SMLimit:     c.Info.DeviceSmLimit(i),
+		})
+	}
+	return res
+}
+
+func (s *APIServer) healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, s.nvmlCheck())
+}
+
+func (s *APIServer) readyz(w http.ResponseWriter, r *http.Request) {
+	err := s.nvmlCheck()
+	if err == nil {
+		if _, err = s.client.Discovery().ServerVersion(); err != nil {
+			err = fmt.Errorf("api server unreachable: %w", err)
+		}
+	}
+	writeHealth(w, err)
+}
+
+func writeHealth(w http.ResponseWriter, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+)
+
+func TestAPIServer(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "infer", Namespace: "default", UID: types.UID("uid1")},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(pod))
+	usage := &meteredUsage{
+		fakeUsage: fakeUsage{uuids: []string{"GPU-0"}, priority: 1, recentKernel: -1, utilizationSwitch: 1, smLimit: [16]uint64{30}},
+		memory:    1 << 30,
+		smUtil:    40,
+	}
+	containers := map[string]*nvidia.ContainerUsage{
+		"uid1_main":  {PodUID: "uid1", ContainerName: "main", Info: usage},
+		"uid2_other": {PodUID: "uid2", ContainerName: "other", Info: active(0, "GPU-1")},
+	}
+	var nvmlErr error
+	s := newAPIServer(func(f func(map[string]*nvidia.ContainerUsage)) { f(containers) },
+		fake.NewSimpleClientset(), listerscorev1.NewPodLister(indexer), func() error { return nvmlErr })
+	mux := http.NewServeMux()
+	s.Register(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/containers")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list []ContainerStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	require.Equal(t, ContainerStatus{
+		Namespace: "default",
+		Pod:       "infer",
+		PodUID:    "uid1",
+		Container: "main",
+		Devices: []DeviceStatus{
+			{Index: 0, UUID: "GPU-0", MemoryUsed: 1 << 30, SMUtil: 40, SMLimit: 30},
+		},
+		Priority:          1,
+		UtilizationSwitch: 1,
+		Blocked:           true,
+	}, list[0])
+	require.Empty(t, list[1].Pod)
+
+	rec = get("/api/v1/pods/default/infer")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status PodStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, "uid1", status.PodUID)
+	require.Len(t, status.Containers, 1)
+	require.Equal(t, "main", status.Containers[0].Container)
+
+	require.Equal(t, http.StatusNotFound, get("/api/v1/pods/default/missing").Code)
+
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+	require.Equal(t, http.StatusOK, get("/readyz").Code)
+	nvmlErr = errors.New("nvml unavailable")
+	require.Equal(t, http.StatusServiceUnavailable, get("/healthz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+}
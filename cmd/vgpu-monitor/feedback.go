@@ -17,54 +17,183 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
+	"os"
+	"strconv"
 	"time"
 
-	"volcano.sh/k8s-device-plugin/pkg/config"
 	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
 )
 
+// UtilizationPerDevice counts the recently active containers on a device per
+// priority level, level 0 being the highest priority.
 type UtilizationPerDevice []int
 
-func CheckBlocking(utSwitchOn map[string]UtilizationPerDevice, p int, c *nvidia.ContainerUsage) bool {
+// PreemptPolicy selects when a container is blocked from launching kernels.
+type PreemptPolicy string
+
+// YieldPolicy selects when the core limit of a container is enforced.
+type YieldPolicy string
+
+const (
+	// PreemptHigher blocks a container while a container of higher priority
+	// is active on one of its devices.
+	PreemptHigher PreemptPolicy = "higher"
+	// PreemptNone never blocks a container.
+	PreemptNone PreemptPolicy = "none"
+
+	// YieldContended enforces the core limit while a container of higher
+	// priority, or another one of the same priority, is active on one of its
+	// devices. A container alone on its devices may use them fully.
+	YieldContended YieldPolicy = "contended"
+	// YieldHigher enforces the core limit only while a container of higher
+	// priority is active on one of its devices.
+	YieldHigher YieldPolicy = "higher"
+	// YieldAlways always enforces the core limit.
+	YieldAlways YieldPolicy = "always"
+
+	defaultPriorityLevels = 2
+)
+
+// Policy decides how containers sharing a device yield to each other
+// depending on their priority.
+type Policy struct {
+	// Levels is the number of priority levels. Priorities outside of
+	// [0, Levels) are clamped into it.
+	Levels  int
+	Preempt PreemptPolicy
+	Yield   YieldPolicy
+	// PriorityResource is the extended resource a container sets its
+	// priority with. The priority libvgpu put in the shared region is used
+	// when it is empty or the container does not set it.
+	PriorityResource string
+}
+
+// NewPolicyFromEnv builds the policy from the PRIORITY_LEVELS,
+// PREEMPT_POLICY, YIELD_POLICY and PRIORITY_RESOURCE_NAME environment
+// variables.
+func NewPolicyFromEnv() (*Policy, error) {
+	p := &Policy{
+		Levels:           defaultPriorityLevels,
+		Preempt:          PreemptHigher,
+		Yield:            YieldContended,
+		PriorityResource: os.Getenv("PRIORITY_RESOURCE_NAME"),
+	}
+	if v := os.Getenv("PRIORITY_LEVELS"); v != "" {
+		levels, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRIORITY_LEVELS %q: %w", v, err)
+		}
+		p.Levels = levels
+	}
+	if v := os.Getenv("PREEMPT_POLICY"); v != "" {
+		p.Preempt = PreemptPolicy(v)
+	}
+	if v := os.Getenv("YIELD_POLICY"); v != "" {
+		p.Yield = YieldPolicy(v)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks that the policy is usable.
+func (pol *Policy) Validate() error {
+	if pol.Levels < 1 {
+		return fmt.Errorf("priority levels must be at least 1, got %d", pol.Levels)
+	}
+	switch pol.Preempt {
+	case PreemptHigher, PreemptNone:
+	default:
+		return fmt.Errorf("unknown preempt policy %q", pol.Preempt)
+	}
+	switch pol.Yield {
+	case YieldContended, YieldHigher, YieldAlways:
+	default:
+		return fmt.Errorf("unknown yield policy %q", pol.Yield)
+	}
+	return nil
+}
+
+// level clamps a priority into the configured levels.
+func (pol *Policy) level(priority int) int {
+	if priority < 0 {
+		return 0
+	}
+	if priority >= pol.Levels {
+		return pol.Levels - 1
+	}
+	return priority
+}
+
+// higherActive checks whether a container of higher priority than p is
+// active on one of the devices of c.
+func higherActive(utSwitchOn map[string]UtilizationPerDevice, p int, c *nvidia.ContainerUsage) bool {
 	for i := 0; i < c.Info.DeviceMax(); i++ {
-		uuid := c.Info.DeviceUUID(i)
-		_, ok := utSwitchOn[uuid]
-		if ok {
-			for i := 0; i < p; i++ {
-				if utSwitchOn[uuid][i] > 0 {
-					return true
-				}
+		if !c.Info.IsValidUUID(i) {
+			continue
+		}
+		counts, ok := utSwitchOn[c.Info.DeviceUUID(i)]
+		if !ok {
+			continue
+		}
+		for level := 0; level < p; level++ {
+			if counts[level] > 0 {
+				return true
 			}
-			return false
 		}
 	}
 	return false
 }
 
-// Check whether task with higher priority use GPU or there are other tasks with the same priority.
-func CheckPriority(utSwitchOn map[string]UtilizationPerDevice, p int, c *nvidia.ContainerUsage) bool {
+// peersActive checks whether more than one container of priority p is active
+// on one of the devices of c.
+func peersActive(utSwitchOn map[string]UtilizationPerDevice, p int, c *nvidia.ContainerUsage) bool {
 	for i := 0; i < c.Info.DeviceMax(); i++ {
-		uuid := c.Info.DeviceUUID(i)
-		_, ok := utSwitchOn[uuid]
-		if ok {
-			for i := 0; i < p; i++ {
-				if utSwitchOn[uuid][i] > 0 {
-					return true
-				}
-			}
-			if utSwitchOn[uuid][p] > 1 {
-				return true
-			}
+		if !c.Info.IsValidUUID(i) {
+			continue
+		}
+		if counts, ok := utSwitchOn[c.Info.DeviceUUID(i)]; ok && counts[p] > 1 {
+			return true
 		}
 	}
 	return false
 }
 
-func Observe(lister *nvidia.ContainerLister) {
+// CheckBlocking checks whether the container of priority p should be blocked
+// from launching kernels.
+func (pol *Policy) CheckBlocking(utSwitchOn map[string]UtilizationPerDevice, p int, c *nvidia.ContainerUsage) bool {
+	switch pol.Preempt {
+	case PreemptNone:
+		return false
+	default:
+		return higherActive(utSwitchOn, p, c)
+	}
+}
+
+// CheckPriority checks whether the core limit of the container of priority p
+// should be enforced.
+func (pol *Policy) CheckPriority(utSwitchOn map[string]UtilizationPerDevice, p int, c *nvidia.ContainerUsage) bool {
+	switch pol.Yield {
+	case YieldAlways:
+		return true
+	case YieldHigher:
+		return higherActive(utSwitchOn, p, c)
+	default:
+		return higherActive(utSwitchOn, p, c) || peersActive(utSwitchOn, p, c)
+	}
+}
+
+// Observe counts the recently active containers per device and priority and
+// updates the blocking and utilization switches in their shared regions.
+// priorityOf returns the priority of a container before clamping.
+func (pol *Policy) Observe(containers map[string]*nvidia.ContainerUsage, priorityOf func(*nvidia.ContainerUsage) int) {
 	utSwitchOn := map[string]UtilizationPerDevice{}
-	containers := lister.ListContainers()
 
 	for _, c := range containers {
 		recentKernel := c.Info.GetRecentKernel()
@@ -78,19 +207,19 @@ func Observe(lister *nvidia.ContainerLister) {
 					}
 					uuid := c.Info.DeviceUUID(i)
 					if len(utSwitchOn[uuid]) == 0 {
-						utSwitchOn[uuid] = []int{0, 0}
+						utSwitchOn[uuid] = make(UtilizationPerDevice, pol.Levels)
 					}
-					utSwitchOn[uuid][c.Info.GetPriority()]++
+					utSwitchOn[uuid][pol.level(priorityOf(c))]++
 				}
 			}
 			c.Info.SetRecentKernel(recentKernel)
 		}
 	}
 	for idx, c := range containers {
-		priority := c.Info.GetPriority()
+		priority := pol.level(priorityOf(c))
 		recentKernel := c.Info.GetRecentKernel()
 		utilizationSwitch := c.Info.GetUtilizationSwitch()
-		if CheckBlocking(utSwitchOn, priority, c) {
+		if pol.CheckBlocking(utSwitchOn, priority, c) {
 			if recentKernel >= 0 {
 				klog.Infof("utSwitchon=%v", utSwitchOn)
 				klog.Infof("Setting Blocking to on %v", idx)
@@ -103,7 +232,7 @@ func Observe(lister *nvidia.ContainerLister) {
 				c.Info.SetRecentKernel(0)
 			}
 		}
-		if CheckPriority(utSwitchOn, priority, c) {
+		if pol.CheckPriority(utSwitchOn, priority, c) {
 			if utilizationSwitch != 1 {
 				klog.Infof("utSwitchon=%v", utSwitchOn)
 				klog.Infof("Setting UtilizationSwitch to on %v", idx)
@@ -119,15 +248,41 @@ func Observe(lister *nvidia.ContainerLister) {
 	}
 }
 
-func watchAndFeedback(lister *nvidia.ContainerLister) {
-	config.Nvml().Init()
+// podPriorities returns the priority each container on the node requests
+// through the priority resource, keyed like the container list.
+func (pol *Policy) podPriorities(lister *nvidia.ContainerLister) map[string]int {
+	res := map[string]int{}
+	if pol.PriorityResource == "" {
+		return res
+	}
+	pods, err := lister.PodLister().List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list pods: %v", err)
+		return res
+	}
+	for _, pod := range pods {
+		for _, ctr := range pod.Spec.Containers {
+			q, ok := ctr.Resources.Limits[corev1.ResourceName(pol.PriorityResource)]
+			if !ok {
+				continue
+			}
+			res[fmt.Sprintf("%s_%s", pod.UID, ctr.Name)] = int(q.Value())
+		}
+	}
+	return res
+}
+
+func watchAndFeedback(lister *nvidia.ContainerLister, policy *Policy) {
 	for {
 		time.Sleep(time.Second * 5)
-		err := lister.Update()
-		if err != nil {
-			klog.Errorf("Failed to update container list: %v", err)
-			continue
-		}
-		Observe(lister)
+		priorities := policy.podPriorities(lister)
+		lister.ReadContainers(func(containers map[string]*nvidia.ContainerUsage) {
+			policy.Observe(containers, func(c *nvidia.ContainerUsage) int {
+				if p, ok := priorities[fmt.Sprintf("%s_%s", c.PodUID, c.ContainerName)]; ok {
+					return p
+				}
+				return c.Info.GetPriority()
+			})
+		})
 	}
 }
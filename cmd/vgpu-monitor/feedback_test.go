@@ -0,0 +1,251 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+)
+
+// fakeUsage implements nvidia.UsageInfo for the devices in uuids.
+type fakeUsage struct {
+	uuids             []string
+	priority          int
+	recentKernel      int32
+	utilizationSwitch int32
+	lastKernelTime    int64
+	memoryLimit       [16]uint64
+	smLimit           [16]uint64
+}
+
+var _ nvidia.UsageInfo = (*fakeUsage)(nil)
+
+func (f *fakeUsage) DeviceMax() int                         { return 16 }
+func (f *fakeUsage) DeviceNum() int                         { return len(f.uuids) }
+func (f *fakeUsage) DeviceMemoryContextSize(idx int) uint64 { return 0 }
+func (f *fakeUsage) DeviceMemoryModuleSize(idx int) uint64  { return 0 }
+func (f *fakeUsage) DeviceMemoryBufferSize(idx int) uint64  { return 0 }
+func (f *fakeUsage) DeviceMemoryOffset(idx int) uint64      { return 0 }
+func (f *fakeUsage) DeviceMemoryTotal(idx int) uint64       { return 0 }
+func (f *fakeUsage) DeviceSmUtil(idx int) uint64            { return 0 }
+func (f *fakeUsage) IsValidUUID(idx int) bool               { return idx < len(f.uuids) }
+func (f *fakeUsage) DeviceMemoryLimit(idx int) uint64       { return f.memoryLimit[idx] }
+func (f *fakeUsage) SetDeviceMemoryLimit(idx int, v uint64) { f.memoryLimit[idx] = v }
+func (f *fakeUsage) DeviceSmLimit(idx int) uint64           { return f.smLimit[idx] }
+func (f *fakeUsage) SetDeviceSmLimit(idx int, v uint64)     { f.smLimit[idx] = v }
+func (f *fakeUsage) LastKernelTime() int64                  { return f.lastKernelTime }
+func (f *fakeUsage) GetPriority() int                       { return f.priority }
+func (f *fakeUsage) GetRecentKernel() int32                 { return f.recentKernel }
+func (f *fakeUsage) SetRecentKernel(v int32)                { f.recentKernel = v }
+func (f *fakeUsage) GetUtilizationSwitch() int32            { return f.utilizationSwitch }
+func (f *fakeUsage) SetUtilizationSwitch(v int32)           { f.utilizationSwitch = v }
+
+func (f *fakeUsage) DeviceUUID(idx int) string {
+	if idx < len(f.uuids) {
+		return f.uuids[idx]
+	}
+	return ""
+}
+
+// active returns a container that launched a kernel recently.
+func active(priority int, uuids ...string) *fakeUsage {
+	return &fakeUsage{uuids: uuids, priority: priority, recentKernel: 2}
+}
+
+// idle returns a container that did not launch a kernel recently.
+func idle(priority int, uuids ...string) *fakeUsage {
+	return &fakeUsage{uuids: uuids, priority: priority}
+}
+
+func sharedRegionPriority(c *nvidia.ContainerUsage) int {
+	return c.Info.GetPriority()
+}
+
+func TestPolicyObserve(t *testing.T) {
+	testCases := []struct {
+		description    string
+		policy         Policy
+		containers     map[string]*fakeUsage
+		expectBlocked  []string
+		expectSwitchOn []string
+	}{
+		{
+			description: "single container runs unrestricted",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"a": active(0, "GPU-0"),
+			},
+		},
+		{
+			description: "same priority containers are both limited",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"a": active(1, "GPU-0"),
+				"b": active(1, "GPU-0"),
+			},
+			expectSwitchOn: []string{"a", "b"},
+		},
+		{
+			description: "high priority blocks low priority on a shared device",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"high": active(0, "GPU-0"),
+				"low":  active(1, "GPU-0"),
+				"away": active(1, "GPU-1"),
+			},
+			expectBlocked:  []string{"low"},
+			expectSwitchOn: []string{"low"},
+		},
+		{
+			description: "second device of a multi-device container is checked",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"high": active(0, "GPU-1"),
+				"low":  active(1, "GPU-0", "GPU-1"),
+			},
+			expectBlocked:  []string{"low"},
+			expectSwitchOn: []string{"low"},
+		},
+		{
+			description: "more than two levels",
+			policy:      Policy{Levels: 4, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"p1": active(1, "GPU-0"),
+				"p2": active(2, "GPU-0"),
+				"p3": active(3, "GPU-0"),
+			},
+			expectBlocked:  []string{"p2", "p3"},
+			expectSwitchOn: []string{"p2", "p3"},
+		},
+		{
+			description: "priorities beyond the levels are clamped",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"high": active(0, "GPU-0"),
+				"low":  active(7, "GPU-0"),
+			},
+			expectBlocked:  []string{"low"},
+			expectSwitchOn: []string{"low"},
+		},
+		{
+			description: "idle high priority container does not block",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"high": idle(0, "GPU-0"),
+				"low":  active(1, "GPU-0"),
+			},
+		},
+		{
+			description: "preempt none never blocks",
+			policy:      Policy{Levels: 2, Preempt: PreemptNone, Yield: YieldContended},
+			containers: map[string]*fakeUsage{
+				"high": active(0, "GPU-0"),
+				"low":  active(1, "GPU-0"),
+			},
+			expectSwitchOn: []string{"low"},
+		},
+		{
+			description: "yield higher ignores peers of the same priority",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldHigher},
+			containers: map[string]*fakeUsage{
+				"a": active(1, "GPU-0"),
+				"b": active(1, "GPU-0"),
+			},
+		},
+		{
+			description: "yield always limits every container",
+			policy:      Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldAlways},
+			containers: map[string]*fakeUsage{
+				"a": active(0, "GPU-0"),
+			},
+			expectSwitchOn: []string{"a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.NoError(t, tc.policy.Validate())
+			containers := map[string]*nvidia.ContainerUsage{}
+			for name, info := range tc.containers {
+				containers[name] = &nvidia.ContainerUsage{Info: info}
+			}
+
+			tc.policy.Observe(containers, sharedRegionPriority)
+
+			for name, info := range tc.containers {
+				require.Equal(t, contains(tc.expectBlocked, name), info.recentKernel < 0, "blocking of %s", name)
+				require.Equal(t, contains(tc.expectSwitchOn, name), info.utilizationSwitch == 1, "utilization switch of %s", name)
+			}
+		})
+	}
+}
+
+func TestPolicyObserveUnblocks(t *testing.T) {
+	policy := Policy{Levels: 2, Preempt: PreemptHigher, Yield: YieldContended}
+	high := active(0, "GPU-0")
+	low := active(1, "GPU-0")
+	containers := map[string]*nvidia.ContainerUsage{
+		"high": {Info: high},
+		"low":  {Info: low},
+	}
+
+	policy.Observe(containers, sharedRegionPriority)
+	require.Equal(t, int32(-1), low.recentKernel)
+
+	// The high priority container stops launching kernels: one tick later it
+	// no longer counts as active and the low priority one is released.
+	policy.Observe(containers, sharedRegionPriority)
+	require.Equal(t, int32(0), low.recentKernel)
+	require.Equal(t, int32(0), low.utilizationSwitch)
+}
+
+func TestPolicyObservePriorityOverride(t *testing.T) {
+	policy := Policy{Levels: 3, Preempt: PreemptHigher, Yield: YieldContended}
+	a := active(0, "GPU-0")
+	b := active(0, "GPU-0")
+	containers := map[string]*nvidia.ContainerUsage{
+		"a": {Info: a},
+		"b": {Info: b},
+	}
+
+	policy.Observe(containers, func(c *nvidia.ContainerUsage) int {
+		if c.Info == b {
+			return 2
+		}
+		return c.Info.GetPriority()
+	})
+	require.Equal(t, int32(1), a.recentKernel)
+	require.Equal(t, int32(-1), b.recentKernel)
+}
+
+func TestPolicyValidate(t *testing.T) {
+	require.Error(t, (&Policy{Levels: 0, Preempt: PreemptHigher, Yield: YieldContended}).Validate())
+	require.Error(t, (&Policy{Levels: 2, Preempt: "sometimes", Yield: YieldContended}).Validate())
+	require.Error(t, (&Policy{Levels: 2, Preempt: PreemptHigher, Yield: "never"}).Validate())
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// IdleSinceAnnotation is set on a pod whose vGPU containers have all been
+	// idle for longer than the idle threshold, to the time they became idle.
+	// A reclaim action can key off it; it is removed once the pod launches a
+	// kernel again.
+	IdleSinceAnnotation = "volcano.sh/vgpu-idle-since"
+
+	reasonIdle   = "VGPUIdle"
+	reasonActive = "VGPUActive"
+)
+
+// IdleDetector finds containers that have not launched a kernel for longer
+// than Threshold and reports them through Events, a metric and optionally a
+// pod annotation.
+type IdleDetector struct {
+	Threshold time.Duration
+	// Annotate enables IdleSinceAnnotation on idle pods.
+	Annotate bool
+
+	client    kubernetes.Interface
+	podLister listerscorev1.PodLister
+	recorder  record.EventRecorder
+	now       func() time.Time
+
+	mutex sync.Mutex
+	// idle maps the key of each idle container to the time it became idle.
+	idle map[string]time.Time
+}
+
+// NewIdleDetectorFromEnv builds an idle detector from the IDLE_THRESHOLD and
+// IDLE_ANNOTATE environment variables. It returns nil when IDLE_THRESHOLD is
+// not set, which disables idle detection.
+func NewIdleDetectorFromEnv(lister *nvidia.ContainerLister) (*IdleDetector, error) {
+	v := os.Getenv("IDLE_THRESHOLD")
+	if v == "" {
+		return nil, nil
+	}
+	threshold, err := time.ParseDuration(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IDLE_THRESHOLD %q: %w", v, err)
+	}
+	if threshold <= 0 {
+		return nil, fmt.Errorf("IDLE_THRESHOLD must be positive, got %v", threshold)
+	}
+	annotate := false
+	if v := os.Getenv("IDLE_ANNOTATE"); v != "" {
+		annotate, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IDLE_ANNOTATE %q: %w", v, err)
+		}
+	}
+
+	recorder := newEventRecorder(lister.Clientset())
+	return newIdleDetector(threshold, annotate, lister.Clientset(), lister.PodLister(), recorder), nil
+}
+
+// newEventRecorder returns a recorder for the Events the monitor reports on
+// pods.
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: "volcano-vgpu-monitor",
+		Host:      os.Getenv("NODE_NAME"),
+	})
+}
+
+func newIdleDetector(threshold time.Duration, annotate bool, client kubernetes.Interface, podLister listerscorev1.PodLister, recorder record.EventRecorder) *IdleDetector {
+	return &IdleDetector{
+		Threshold: threshold,
+		Annotate:  annotate,
+		client:    client,
+		podLister: podLister,
+		recorder:  recorder,
+		now:       time.Now,
+		idle:      make(map[string]time.Time),
+	}
+}
+
+// IdleSince returns the time the container became idle, if it is idle.
+func (d *IdleDetector) IdleSince(podUID, ctrName string) (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	since, ok := d.idle[podUID+"_"+ctrName]
+	return since, ok
+}
+
+// Check compares the last kernel time of every container against the
+// threshold and reports the containers that became idle or active since the
+// previous check. It returns the updates of the idle annotations, to be
+// patched once the containers are no longer read.
+func (d *IdleDetector) Check(containers map[string]*nvidia.ContainerUsage) []annotationUpdate {
+	pods, err := d.podLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list pods: %v", err)
+		return nil
+	}
+	podsByUID := make(map[string]*corev1.Pod, len(pods))
+	for _, pod := range pods {
+		podsByUID[string(pod.UID)] = pod
+	}
+
+	now := d.now()
+	// podIdleSince holds, for every pod with a monitored container, the time
+	// its last container became idle, or the zero time if one is active.
+	podIdleSince := map[string]time.Time{}
+	idle := make(map[string]time.Time, len(containers))
+	for key, c := range containers {
+		if c.Info == nil {
+			continue
+		}
+		lastKernelTime := c.Info.LastKernelTime()
+		if lastKernelTime <= 0 {
+			// no kernel launched yet, or not reported by this libvgpu version
+			continue
+		}
+		since := time.Unix(lastKernelTime, 0)
+		if now.Sub(since) < d.Threshold {
+			podIdleSince[c.PodUID] = time.Time{}
+			continue
+		}
+		idle[key] = since
+		if latest, seen := podIdleSince[c.PodUID]; !seen || (!latest.IsZero() && since.After(latest)) {
+			podIdleSince[c.PodUID] = since
+		}
+	}
+
+	d.mutex.Lock()
+	previous := d.idle
+	d.idle = idle
+	d.mutex.Unlock()
+
+	for key, since := range idle {
+		if _, ok := previous[key]; ok {
+			continue
+		}
+		c := containers[key]
+		if pod, ok := podsByUID[c.PodUID]; ok {
+			klog.Infof("Container %s/%s/%s idle since %v", pod.Namespace, pod.Name, c.ContainerName, since)
+			d.recorder.Eventf(pod, corev1.EventTypeWarning, reasonIdle,
+				"Container %s has not launched a GPU kernel since %s", c.ContainerName, since.UTC().Format(time.RFC3339))
+		}
+	}
+	for key := range previous {
+		if _, ok := idle[key]; ok {
+			continue
+		}
+		c, ok := containers[key]
+		if !ok {
+			continue
+		}
+		if pod, ok := podsByUID[c.PodUID]; ok {
+			d.recorder.Eventf(pod, corev1.EventTypeNormal, reasonActive,
+				"Container %s is launching GPU kernels again", c.ContainerName)
+		}
+	}
+
+	if !d.Annotate {
+		return nil
+	}
+	return idleAnnotationUpdates(podsByUID, podIdleSince)
+}
+
+// idleAnnotationUpdates sets IdleSinceAnnotation on pods whose containers are
+// all idle and removes it from the others.
+func idleAnnotationUpdates(podsByUID map[string]*corev1.Pod, podIdleSince map[string]time.Time) []annotationUpdate {
+	var updates []annotationUpdate
+	for uid, since := range podIdleSince {
+		pod, ok := podsByUID[uid]
+		if !ok {
+			continue
+		}
+		current, annotated := pod.Annotations[IdleSinceAnnotation]
+		if since.IsZero() {
+			if annotated {
+				updates = append(updates, annotationUpdate{pod: pod, key: IdleSinceAnnotation})
+			}
+			continue
+		}
+		value := since.UTC().Format(time.RFC3339)
+		if current == value {
+			continue
+		}
+		updates = append(updates, annotationUpdate{pod: pod, key: IdleSinceAnnotation, value: &value})
+	}
+	return updates
+}
+
+// annotationUpdate sets an annotation of a pod, or removes it if value is nil.
+type annotationUpdate struct {
+	pod   *corev1.Pod
+	key   string
+	value *string
+}
+
+// patchPodAnnotations applies updates, logging those that fail. It calls the
+// API server, and is not to be called while the containers are read: that
+// would hold up the lister.
+func patchPodAnnotations(client kubernetes.Interface, updates []annotationUpdate) {
+	for _, u := range updates {
+		if err := patchPodAnnotation(client, u.pod, u.key, u.value); err != nil {
+			klog.Errorf("Failed to update annotation %s of pod %s/%s: %v", u.key, u.pod.Namespace, u.pod.Name, err)
+		}
+	}
+}
+
+// patchPodAnnotation sets a single annotation on a pod, or removes it if value
+// is nil.
+func patchPodAnnotation(client kubernetes.Interface, pod *corev1.Pod, key string, value *string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{key: value},
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Pods(pod.Namespace).Patch(context.Background(), pod.Name, k8stypes.MergePatchType, data, metav1.PatchOptions{})
+	return err
+}
+
+func watchIdle(lister *nvidia.ContainerLister, detector *IdleDetector) {
+	for {
+		time.Sleep(time.Second * 30)
+		var updates []annotationUpdate
+		lister.ReadContainers(func(containers map[string]*nvidia.ContainerUsage) {
+			updates = detector.Check(containers)
+		})
+		patchPodAnnotations(detector.client, updates)
+	}
+}
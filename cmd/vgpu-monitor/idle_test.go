@@ -0,0 +1,92 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+)
+
+func TestIdleDetectorCheck(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "notebook", Namespace: "default", UID: types.UID("uid1")},
+	}
+	client := fake.NewSimpleClientset(pod)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(pod))
+	recorder := record.NewFakeRecorder(10)
+
+	d := newIdleDetector(time.Hour, true, client, listerscorev1.NewPodLister(indexer), recorder)
+	d.now = func() time.Time { return now }
+
+	worker := &fakeUsage{uuids: []string{"GPU-0"}, lastKernelTime: now.Add(-2 * time.Hour).Unix()}
+	sidecar := &fakeUsage{uuids: []string{"GPU-0"}, lastKernelTime: now.Add(-time.Minute).Unix()}
+	containers := map[string]*nvidia.ContainerUsage{
+		"uid1_main":    {PodUID: "uid1", ContainerName: "main", Info: worker},
+		"uid1_sidecar": {PodUID: "uid1", ContainerName: "sidecar", Info: sidecar},
+	}
+
+	// One container is idle, the pod is not.
+	patchPodAnnotations(client, d.Check(containers))
+	since, ok := d.IdleSince("uid1", "main")
+	require.True(t, ok)
+	require.Equal(t, now.Add(-2*time.Hour).Unix(), since.Unix())
+	_, ok = d.IdleSince("uid1", "sidecar")
+	require.False(t, ok)
+	require.Contains(t, <-recorder.Events, reasonIdle)
+	require.NotContains(t, getPod(t, client).Annotations, IdleSinceAnnotation)
+
+	// Both are idle, the pod is annotated with the latest idle time, once the
+	// containers are no longer read.
+	sidecar.lastKernelTime = now.Add(-90 * time.Minute).Unix()
+	updates := d.Check(containers)
+	require.NotContains(t, getPod(t, client).Annotations, IdleSinceAnnotation)
+	patchPodAnnotations(client, updates)
+	require.Contains(t, <-recorder.Events, reasonIdle)
+	require.Empty(t, recorder.Events)
+	updated := getPod(t, client)
+	require.Equal(t, now.Add(-90*time.Minute).Format(time.RFC3339), updated.Annotations[IdleSinceAnnotation])
+
+	// A kernel launch clears the annotation.
+	require.NoError(t, indexer.Update(updated))
+	worker.lastKernelTime = now.Unix()
+	patchPodAnnotations(client, d.Check(containers))
+	require.Contains(t, <-recorder.Events, reasonActive)
+	require.NotContains(t, getPod(t, client).Annotations, IdleSinceAnnotation)
+	_, ok = d.IdleSince("uid1", "main")
+	require.False(t, ok)
+}
+
+func getPod(t *testing.T, client *fake.Clientset) *corev1.Pod {
+	t.Helper()
+	pod, err := client.CoreV1().Pods("default").Get(context.Background(), "notebook", metav1.GetOptions{})
+	require.NoError(t, err)
+	return pod
+}
@@ -17,8 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"net/http"
+
+	"volcano.sh/k8s-device-plugin/pkg/config"
 	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
 
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"k8s.io/klog/v2"
 )
 
@@ -26,13 +30,49 @@ func main() {
 	if err := ValidateEnvVars(); err != nil {
 		klog.Fatalf("Failed to validate environment variables: %v", err)
 	}
+	policy, err := NewPolicyFromEnv()
+	if err != nil {
+		klog.Fatalf("Failed to load feedback policy: %v", err)
+	}
 	containerLister, err := nvidia.NewContainerLister()
 	if err != nil {
 		klog.Fatalf("Failed to create container lister: %v", err)
 	}
+	idleDetector, err := NewIdleDetectorFromEnv(containerLister)
+	if err != nil {
+		klog.Fatalf("Failed to create idle detector: %v", err)
+	}
+	resizer, err := NewResizerFromEnv(containerLister)
+	if err != nil {
+		klog.Fatalf("Failed to create resizer: %v", err)
+	}
+	accountant, err := NewAccountantFromEnv(containerLister)
+	if err != nil {
+		klog.Fatalf("Failed to create accountant: %v", err)
+	}
+	// The API server and the metrics query NVML as soon as they serve.
+	// Without it the monitor still serves the container metrics and runs the
+	// feedback, which do not need it, and /healthz fails.
+	if ret := config.Nvml().Init(); ret != nvml.SUCCESS {
+		klog.Errorf("Failed to initialize NVML: %v", ret)
+	}
 	errchannel := make(chan error)
-	go initMetrics(containerLister)
-	go watchAndFeedback(containerLister)
+	stopCh := make(chan struct{})
+	go func() {
+		if err := containerLister.Run(stopCh); err != nil {
+			klog.Fatalf("Failed to watch containers: %v", err)
+		}
+	}()
+	NewAPIServer(containerLister).Register(http.DefaultServeMux)
+	go initMetrics(containerLister, idleDetector)
+	go watchAndFeedback(containerLister, policy)
+	go watchResize(containerLister, resizer)
+	if idleDetector != nil {
+		go watchIdle(containerLister, idleDetector)
+	}
+	if accountant != nil {
+		go watchAccounting(containerLister, accountant)
+	}
 	for {
 		err := <-errchannel
 		klog.Errorf("failed to serve: %v", err)
@@ -31,8 +31,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/informers"
 	listerscorev1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog/v2"
 )
@@ -53,6 +53,7 @@ type ClusterManager struct {
 	// Contains many more fields not listed in this example.
 	PodLister       listerscorev1.PodLister
 	containerLister *nvidia.ContainerLister
+	idleDetector    *IdleDetector
 }
 
 // ReallyExpensiveAssessmentOfTheSystemState is a mock for the data gathering a
@@ -104,6 +105,11 @@ var (
 		"vGPU device limit",
 		[]string{"podnamespace", "podname", "ctrname", "vdeviceid", "deviceuuid"}, nil,
 	)
+	ctrvGPUcorelimitdesc = prometheus.NewDesc(
+		"vGPU_device_core_limit_in_percent",
+		"vGPU device core limit, 0 when not limited",
+		[]string{"podnamespace", "podname", "ctrname", "vdeviceid", "deviceuuid"}, nil,
+	)
 	ctrDeviceMemorydesc = prometheus.NewDesc(
 		"Device_memory_desc_of_container",
 		"Container device meory description",
@@ -119,6 +125,11 @@ var (
 		"Container device last kernel description",
 		[]string{"podnamespace", "podname", "ctrname", "vdeviceid", "deviceuuid"}, nil,
 	)
+	ctrIdleDesc = prometheus.NewDesc(
+		"vGPU_container_idle_seconds",
+		"Seconds since the last kernel of a container idle for longer than the idle threshold",
+		[]string{"podnamespace", "podname", "ctrname"}, nil,
+	)
 )
 
 // Describe is implemented with DescribeByCollect. That's possible because the
@@ -128,7 +139,9 @@ func (cc ClusterManagerCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- hostGPUdesc
 	ch <- ctrvGPUdesc
 	ch <- ctrvGPUlimitdesc
+	ch <- ctrvGPUcorelimitdesc
 	ch <- hostGPUUtilizationdesc
+	ch <- ctrIdleDesc
 	//prometheus.DescribeByCollect(cc, ch)
 }
 
@@ -190,96 +203,116 @@ func (cc ClusterManagerCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 	nowSec := time.Now().Unix()
 
-	containers := containerLister.ListContainers()
-	for _, pod := range pods {
-		for _, c := range containers {
-			//for sridx := range srPodList {
-			//	if srPodList[sridx].sr == nil {
-			//		continue
-			//	}
-			if c.Info == nil {
-				continue
-			}
-			//podUID := strings.Split(srPodList[sridx].idstr, "_")[0]
-			//ctrName := strings.Split(srPodList[sridx].idstr, "_")[1]
-			podUID := c.PodUID
-			ctrName := c.ContainerName
-			if strings.Compare(string(pod.UID), podUID) != 0 {
-				continue
-			}
-			fmt.Println("Pod matched!", pod.Name, pod.Namespace, pod.Labels)
-			for _, ctr := range pod.Spec.Containers {
-				if strings.Compare(ctr.Name, ctrName) != 0 {
+	containerLister.ReadContainers(func(containers map[string]*nvidia.ContainerUsage) {
+		for _, pod := range pods {
+			for _, c := range containers {
+				//for sridx := range srPodList {
+				//	if srPodList[sridx].sr == nil {
+				//		continue
+				//	}
+				if c.Info == nil {
 					continue
 				}
-				fmt.Println("container matched", ctr.Name)
-				//err := setHostPid(pod, pod.Status.ContainerStatuses[ctridx], &srPodList[sridx])
-				//if err != nil {
-				//	fmt.Println("setHostPid filed", err.Error())
-				//}
-				//fmt.Println("sr.list=", srPodList[sridx].sr)
-				podlabels := make(map[string]string)
-				for idx, val := range pod.Labels {
-					idxfix := strings.ReplaceAll(idx, "-", "_")
-					valfix := strings.ReplaceAll(val, "-", "_")
-					podlabels[idxfix] = valfix
+				//podUID := strings.Split(srPodList[sridx].idstr, "_")[0]
+				//ctrName := strings.Split(srPodList[sridx].idstr, "_")[1]
+				podUID := c.PodUID
+				ctrName := c.ContainerName
+				if strings.Compare(string(pod.UID), podUID) != 0 {
+					continue
 				}
-				for i := 0; i < c.Info.DeviceNum(); i++ {
-					uuid := c.Info.DeviceUUID(i)[0:40]
-					if !utf8.ValidString(uuid) {
-						klog.Warningf("skipping device %d for pod %s/%s: UUID contains invalid UTF-8 (shared memory not yet initialized)", i, pod.Namespace, pod.Name)
+				fmt.Println("Pod matched!", pod.Name, pod.Namespace, pod.Labels)
+				for _, ctr := range pod.Spec.Containers {
+					if strings.Compare(ctr.Name, ctrName) != 0 {
 						continue
 					}
-					memoryTotal := c.Info.DeviceMemoryTotal(i)
-					memoryLimit := c.Info.DeviceMemoryLimit(i)
-					memoryContextSize := c.Info.DeviceMemoryContextSize(i)
-					memoryModuleSize := c.Info.DeviceMemoryModuleSize(i)
-					memoryBufferSize := c.Info.DeviceMemoryBufferSize(i)
-					memoryOffset := c.Info.DeviceMemoryOffset(i)
-					smUtil := c.Info.DeviceSmUtil(i)
-					lastKernelTime := c.Info.LastKernelTime()
-
-					//fmt.Println("uuid=", uuid, "length=", len(uuid))
-					ch <- prometheus.MustNewConstMetric(
-						ctrvGPUdesc,
-						prometheus.GaugeValue,
-						float64(memoryTotal),
-						pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid, /*,string(sr.sr.uuids[i].uuid[:])*/
-					)
-					ch <- prometheus.MustNewConstMetric(
-						ctrvGPUlimitdesc,
-						prometheus.GaugeValue,
-						float64(memoryLimit),
-						pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid, /*,string(sr.sr.uuids[i].uuid[:])*/
-					)
-					ch <- prometheus.MustNewConstMetric(
-						ctrDeviceMemorydesc,
-						prometheus.CounterValue,
-						float64(memoryTotal),
-						pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
-						fmt.Sprint(memoryContextSize), fmt.Sprint(memoryModuleSize), fmt.Sprint(memoryBufferSize), fmt.Sprint(memoryOffset),
-					)
-					ch <- prometheus.MustNewConstMetric(
-						ctrDeviceUtilizationdesc,
-						prometheus.GaugeValue,
-						float64(smUtil),
-						pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
-					)
-					if lastKernelTime > 0 {
-						lastSec := nowSec - lastKernelTime
-						if lastSec < 0 {
-							lastSec = 0
-						}
+					fmt.Println("container matched", ctr.Name)
+					if since, ok := cc.ClusterManager.idleDetector.IdleSince(podUID, ctrName); ok {
 						ch <- prometheus.MustNewConstMetric(
-							ctrDeviceLastKernelDesc,
+							ctrIdleDesc,
 							prometheus.GaugeValue,
-							float64(lastSec),
-							pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+							float64(nowSec-since.Unix()),
+							pod.Namespace, pod.Name, ctrName,
 						)
 					}
+					//err := setHostPid(pod, pod.Status.ContainerStatuses[ctridx], &srPodList[sridx])
+					//if err != nil {
+					//	fmt.Println("setHostPid filed", err.Error())
+					//}
+					//fmt.Println("sr.list=", srPodList[sridx].sr)
+					podlabels := make(map[string]string)
+					for idx, val := range pod.Labels {
+						idxfix := strings.ReplaceAll(idx, "-", "_")
+						valfix := strings.ReplaceAll(val, "-", "_")
+						podlabels[idxfix] = valfix
+					}
+					collectDeviceMetrics(ch, pod, ctrName, c.Info, nowSec)
 				}
 			}
 		}
+	})
+}
+
+// collectDeviceMetrics sends the metrics of every device of a container.
+func collectDeviceMetrics(ch chan<- prometheus.Metric, pod *corev1.Pod, ctrName string, info nvidia.UsageInfo, nowSec int64) {
+	for i := 0; i < info.DeviceNum(); i++ {
+		uuid := deviceUUID(info, i)
+		if !utf8.ValidString(uuid) {
+			klog.Warningf("skipping device %d for pod %s/%s: UUID contains invalid UTF-8 (shared memory not yet initialized)", i, pod.Namespace, pod.Name)
+			continue
+		}
+		memoryTotal := info.DeviceMemoryTotal(i)
+		memoryLimit := info.DeviceMemoryLimit(i)
+		memoryContextSize := info.DeviceMemoryContextSize(i)
+		memoryModuleSize := info.DeviceMemoryModuleSize(i)
+		memoryBufferSize := info.DeviceMemoryBufferSize(i)
+		memoryOffset := info.DeviceMemoryOffset(i)
+		smUtil := info.DeviceSmUtil(i)
+		smLimit := info.DeviceSmLimit(i)
+		lastKernelTime := info.LastKernelTime()
+
+		ch <- prometheus.MustNewConstMetric(
+			ctrvGPUdesc,
+			prometheus.GaugeValue,
+			float64(memoryTotal),
+			pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			ctrvGPUlimitdesc,
+			prometheus.GaugeValue,
+			float64(memoryLimit),
+			pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			ctrvGPUcorelimitdesc,
+			prometheus.GaugeValue,
+			float64(smLimit),
+			pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			ctrDeviceMemorydesc,
+			prometheus.CounterValue,
+			float64(memoryTotal),
+			pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+			fmt.Sprint(memoryContextSize), fmt.Sprint(memoryModuleSize), fmt.Sprint(memoryBufferSize), fmt.Sprint(memoryOffset),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			ctrDeviceUtilizationdesc,
+			prometheus.GaugeValue,
+			float64(smUtil),
+			pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+		)
+		if lastKernelTime > 0 {
+			lastSec := nowSec - lastKernelTime
+			if lastSec < 0 {
+				lastSec = 0
+			}
+			ch <- prometheus.MustNewConstMetric(
+				ctrDeviceLastKernelDesc,
+				prometheus.GaugeValue,
+				float64(lastSec),
+				pod.Namespace, pod.Name, ctrName, fmt.Sprint(i), uuid,
+			)
+		}
 	}
 }
 
@@ -288,23 +321,20 @@ func (cc ClusterManagerCollector) Collect(ch chan<- prometheus.Metric) {
 // ClusterManager. Finally, it registers the ClusterManagerCollector with a
 // wrapping Registerer that adds the zone as a label. In this way, the metrics
 // collected by different ClusterManagerCollectors do not collide.
-func NewClusterManager(zone string, reg prometheus.Registerer, containerLister *nvidia.ContainerLister) *ClusterManager {
+func NewClusterManager(zone string, reg prometheus.Registerer, containerLister *nvidia.ContainerLister, idleDetector *IdleDetector) *ClusterManager {
 	c := &ClusterManager{
 		Zone:            zone,
+		PodLister:       containerLister.PodLister(),
 		containerLister: containerLister,
+		idleDetector:    idleDetector,
 	}
 
-	informerFactory := informers.NewSharedInformerFactoryWithOptions(containerLister.Clientset(), time.Hour*1)
-	c.PodLister = informerFactory.Core().V1().Pods().Lister()
-	stopCh := make(chan struct{})
-	informerFactory.Start(stopCh)
-
 	cc := ClusterManagerCollector{ClusterManager: c}
 	prometheus.WrapRegistererWith(prometheus.Labels{"zone": zone}, reg).MustRegister(cc)
 	return c
 }
 
-func initMetrics(containerLister *nvidia.ContainerLister) {
+func initMetrics(containerLister *nvidia.ContainerLister, idleDetector *IdleDetector) {
 	// Since we are dealing with custom Collector implementations, it might
 	// be a good idea to try it out with a pedantic registry.
 	klog.Info("Initializing metrics for vGPUmonitor")
@@ -313,7 +343,7 @@ func initMetrics(containerLister *nvidia.ContainerLister) {
 
 	// Construct cluster managers. In real code, we would assign them to
 	// variables to then do something with them.
-	NewClusterManager("vGPU", reg, containerLister)
+	NewClusterManager("vGPU", reg, containerLister, idleDetector)
 
 	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 	log.Fatal(http.ListenAndServe(":9394", nil))
@@ -0,0 +1,57 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCollectDeviceMetricsCoreLimits(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "default", UID: "uid1"}}
+	// Two slices with different core shares, and a device not limited.
+	usage := &fakeUsage{
+		uuids:       []string{"GPU-0", "GPU-1", "GPU-2"},
+		memoryLimit: [16]uint64{1 << 30, 4 << 30, 1 << 30},
+		smLimit:     [16]uint64{30, 80, 0},
+	}
+
+	ch := make(chan prometheus.Metric, 64)
+	collectDeviceMetrics(ch, pod, "main", usage, 0)
+	close(ch)
+
+	limits := map[string]float64{}
+	for m := range ch {
+		if m.Desc() != ctrvGPUcorelimitdesc {
+			continue
+		}
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+		labels := map[string]string{}
+		for _, l := range metric.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		require.Equal(t, "train", labels["podname"])
+		limits[labels["deviceuuid"]] = metric.GetGauge().GetValue()
+	}
+	require.Equal(t, map[string]float64{"GPU-0": 30, "GPU-1": 80, "GPU-2": 0}, limits)
+}
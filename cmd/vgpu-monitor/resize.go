@@ -0,0 +1,269 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ResizeAnnotation requests new vGPU limits for the containers of a
+	// running pod, as a JSON object keyed by container name, e.g.
+	// {"main":{"memory":4096,"cores":30}}. Memory is in MiB per device and
+	// cores in percent of a device; either can be left out to keep the
+	// current limit.
+	ResizeAnnotation = "volcano.sh/vgpu-resize"
+
+	reasonResized        = "VGPUResized"
+	reasonResizeRejected = "VGPUResizeRejected"
+)
+
+// ResizeRequest holds the new limits of a container.
+type ResizeRequest struct {
+	Memory *int64 `json:"memory,omitempty"`
+	Cores  *int64 `json:"cores,omitempty"`
+}
+
+// limitUpdate is a validated ResizeRequest, in shared region units.
+type limitUpdate struct {
+	key     string
+	ctrName string
+	// memory is in bytes, 0 keeps the current limit.
+	memory uint64
+	// cores is in percent, 0 keeps the current limit.
+	cores uint64
+}
+
+// Resizer applies ResizeAnnotation to the shared regions of running
+// containers, within the devices the scheduler reserved for them.
+type Resizer struct {
+	// MemoryFactor is the gpuMemoryFactor of the device config, the unit of
+	// the memory reserved by the scheduler.
+	MemoryFactor int64
+
+	podLister listerscorev1.PodLister
+	recorder  record.EventRecorder
+
+	mutex sync.Mutex
+	// observed maps a pod UID to the last value of its ResizeAnnotation that
+	// was reported on.
+	observed map[string]string
+}
+
+// NewResizerFromEnv builds a resizer, reading the memory factor from
+// GPU_MEMORY_FACTOR.
+func NewResizerFromEnv(lister *nvidia.ContainerLister) (*Resizer, error) {
+	factor := int64(1)
+	if v := os.Getenv("GPU_MEMORY_FACTOR"); v != "" {
+		var err error
+		factor, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || factor <= 0 {
+			return nil, fmt.Errorf("invalid GPU_MEMORY_FACTOR %q: must be a positive integer", v)
+		}
+	}
+	return newResizer(factor, lister.PodLister(), newEventRecorder(lister.Clientset())), nil
+}
+
+func newResizer(factor int64, podLister listerscorev1.PodLister, recorder record.EventRecorder) *Resizer {
+	return &Resizer{
+		MemoryFactor: factor,
+		podLister:    podLister,
+		recorder:     recorder,
+		observed:     make(map[string]string),
+	}
+}
+
+// Resize validates the ResizeAnnotation of every pod and writes the limits
+// into the shared regions of its containers. Limits are written whenever the
+// shared region differs, so that they survive a container restart, while
+// Events are only reported when the annotation changes.
+func (r *Resizer) Resize(containers map[string]*nvidia.ContainerUsage) {
+	pods, err := r.podLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list pods: %v", err)
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	seen := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		value, ok := pod.Annotations[ResizeAnnotation]
+		if !ok {
+			continue
+		}
+		uid := string(pod.UID)
+		seen[uid] = true
+		changed := r.observed[uid] != value
+		r.observed[uid] = value
+
+		updates, err := r.plan(pod, value, containers)
+		if err != nil {
+			if changed {
+				klog.Warningf("Rejected resize of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+				r.recorder.Eventf(pod, corev1.EventTypeWarning, reasonResizeRejected, "Rejected %s: %v", ResizeAnnotation, err)
+			}
+			continue
+		}
+		for _, u := range updates {
+			c, ok := containers[u.key]
+			if !ok || c.Info == nil {
+				continue
+			}
+			if applyLimits(c.Info, u) {
+				klog.Infof("Resized container %s/%s/%s to %s", pod.Namespace, pod.Name, u.ctrName, u)
+			}
+			if changed {
+				r.recorder.Eventf(pod, corev1.EventTypeNormal, reasonResized, "Resized container %s to %s", u.ctrName, u)
+			}
+		}
+	}
+	for uid := range r.observed {
+		if !seen[uid] {
+			delete(r.observed, uid)
+		}
+	}
+}
+
+// plan validates the resize requested by value against the devices reserved
+// for the pod and the memory its containers currently use.
+func (r *Resizer) plan(pod *corev1.Pod, value string, containers map[string]*nvidia.ContainerUsage) ([]limitUpdate, error) {
+	var requests map[string]ResizeRequest
+	if err := json.Unmarshal([]byte(value), &requests); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	reserved, _ := util.DecodePodDevices(pod.Annotations[util.AssignedIDsAnnotations])
+
+	names := make([]string, 0, len(requests))
+	for name := range requests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var updates []limitUpdate
+	for _, name := range names {
+		req := requests[name]
+		idx := -1
+		for i, ctr := range pod.Spec.Containers {
+			if ctr.Name == name {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, fmt.Errorf("no container %s", name)
+		}
+		if idx >= len(reserved) || len(reserved[idx]) == 0 {
+			return nil, fmt.Errorf("container %s has no vGPU", name)
+		}
+		u := limitUpdate{key: fmt.Sprintf("%s_%s", pod.UID, name), ctrName: name}
+		if req.Memory != nil {
+			if *req.Memory <= 0 {
+				return nil, fmt.Errorf("memory of container %s must be positive", name)
+			}
+			for _, dev := range reserved[idx] {
+				if limit := int64(dev.Usedmem) * r.MemoryFactor; *req.Memory > limit {
+					return nil, fmt.Errorf("memory of container %s exceeds the %dMiB reserved on %s", name, limit, dev.UUID)
+				}
+			}
+			u.memory = uint64(*req.Memory) << 20
+		}
+		if req.Cores != nil {
+			if *req.Cores <= 0 || *req.Cores > util.DeviceLimit {
+				return nil, fmt.Errorf("cores of container %s must be between 1 and %d", name, util.DeviceLimit)
+			}
+			for _, dev := range reserved[idx] {
+				// No cores reserved means the container may use the whole device.
+				if dev.Usedcores > 0 && *req.Cores > int64(dev.Usedcores) {
+					return nil, fmt.Errorf("cores of container %s exceed the %d%% reserved on %s", name, dev.Usedcores, dev.UUID)
+				}
+			}
+			u.cores = uint64(*req.Cores)
+		}
+		if c, ok := containers[u.key]; ok && c.Info != nil && u.memory > 0 {
+			for i := 0; i < c.Info.DeviceNum(); i++ {
+				if !c.Info.IsValidUUID(i) {
+					continue
+				}
+				if used := c.Info.DeviceMemoryTotal(i); u.memory < used {
+					return nil, fmt.Errorf("memory of container %s is below the %dMiB in use on %s", name, used>>20, deviceUUID(c.Info, i))
+				}
+			}
+		}
+		updates = append(updates, u)
+	}
+	return updates, nil
+}
+
+// applyLimits writes the limits of u into a shared region and reports
+// whether it changed.
+func applyLimits(info nvidia.UsageInfo, u limitUpdate) bool {
+	changed := false
+	for i := 0; i < info.DeviceNum(); i++ {
+		if !info.IsValidUUID(i) {
+			continue
+		}
+		if u.memory > 0 && info.DeviceMemoryLimit(i) != u.memory {
+			info.SetDeviceMemoryLimit(i, u.memory)
+			changed = true
+		}
+		if u.cores > 0 && info.DeviceSmLimit(i) != u.cores {
+			info.SetDeviceSmLimit(i, u.cores)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (u limitUpdate) String() string {
+	var s []string
+	if u.memory > 0 {
+		s = append(s, fmt.Sprintf("memory=%dMiB", u.memory>>20))
+	}
+	if u.cores > 0 {
+		s = append(s, fmt.Sprintf("cores=%d%%", u.cores))
+	}
+	return strings.Join(s, " ")
+}
+
+func deviceUUID(info nvidia.UsageInfo, idx int) string {
+	return strings.TrimRight(info.DeviceUUID(idx), "\x00")
+}
+
+func watchResize(lister *nvidia.ContainerLister, resizer *Resizer) {
+	for {
+		time.Sleep(time.Second * 5)
+		lister.ReadContainers(resizer.Resize)
+	}
+}
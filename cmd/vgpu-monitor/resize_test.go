@@ -0,0 +1,135 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"volcano.sh/k8s-device-plugin/pkg/monitor/nvidia"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+func TestResizerResize(t *testing.T) {
+	testCases := []struct {
+		description  string
+		annotation   string
+		expectEvent  string
+		expectMemory uint64
+		expectCores  uint64
+	}{
+		{
+			description:  "memory and cores within the reservation",
+			annotation:   `{"main":{"memory":2048,"cores":20}}`,
+			expectEvent:  reasonResized,
+			expectMemory: 2048 << 20,
+			expectCores:  20,
+		},
+		{
+			description:  "cores only",
+			annotation:   `{"main":{"cores":10}}`,
+			expectEvent:  reasonResized,
+			expectMemory: 4096 << 20,
+			expectCores:  10,
+		},
+		{
+			description:  "memory beyond the reservation",
+			annotation:   `{"main":{"memory":8192}}`,
+			expectEvent:  reasonResizeRejected,
+			expectMemory: 4096 << 20,
+			expectCores:  30,
+		},
+		{
+			description:  "memory below the usage",
+			annotation:   `{"main":{"memory":512}}`,
+			expectEvent:  reasonResizeRejected,
+			expectMemory: 4096 << 20,
+			expectCores:  30,
+		},
+		{
+			description:  "cores beyond the reservation",
+			annotation:   `{"main":{"cores":50}}`,
+			expectEvent:  reasonResizeRejected,
+			expectMemory: 4096 << 20,
+			expectCores:  30,
+		},
+		{
+			description:  "container without vGPU",
+			annotation:   `{"sidecar":{"cores":10}}`,
+			expectEvent:  reasonResizeRejected,
+			expectMemory: 4096 << 20,
+			expectCores:  30,
+		},
+		{
+			description:  "invalid value",
+			annotation:   `main=2048`,
+			expectEvent:  reasonResizeRejected,
+			expectMemory: 4096 << 20,
+			expectCores:  30,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "infer",
+					Namespace: "default",
+					UID:       types.UID("uid1"),
+					Annotations: map[string]string{
+						util.AssignedIDsAnnotations: "GPU-0,NVIDIA,4096,30:;",
+						ResizeAnnotation:            tc.annotation,
+					},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}, {Name: "sidecar"}}},
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			require.NoError(t, indexer.Add(pod))
+			recorder := record.NewFakeRecorder(10)
+			r := newResizer(1, listerscorev1.NewPodLister(indexer), recorder)
+
+			usage := &meteredUsage{fakeUsage: fakeUsage{uuids: []string{"GPU-0"}}, memory: 1 << 30}
+			usage.memoryLimit[0] = 4096 << 20
+			usage.smLimit[0] = 30
+			containers := map[string]*nvidia.ContainerUsage{
+				"uid1_main": {PodUID: "uid1", ContainerName: "main", Info: usage},
+			}
+
+			r.Resize(containers)
+			require.Equal(t, tc.expectMemory, usage.memoryLimit[0])
+			require.Equal(t, tc.expectCores, usage.smLimit[0])
+			require.Len(t, recorder.Events, 1)
+			require.Contains(t, <-recorder.Events, tc.expectEvent)
+
+			// An unchanged annotation is not reported again, but is applied
+			// again to a shared region that was reset.
+			usage.memoryLimit[0] = 4096 << 20
+			usage.smLimit[0] = 30
+			r.Resize(containers)
+			require.Equal(t, tc.expectMemory, usage.memoryLimit[0])
+			require.Equal(t, tc.expectCores, usage.smLimit[0])
+			require.Empty(t, recorder.Events)
+		})
+	}
+}
@@ -22,8 +22,20 @@ import (
 )
 
 var requiredEnvVars = map[string]bool{
-	"HOOK_PATH":     true,
-	"OTHER_ENV_VAR": false,
+	"HOOK_PATH":              true,
+	"OTHER_ENV_VAR":          false,
+	"PRIORITY_LEVELS":        false,
+	"PREEMPT_POLICY":         false,
+	"YIELD_POLICY":           false,
+	"PRIORITY_RESOURCE_NAME": false,
+	"IDLE_THRESHOLD":         false,
+	"IDLE_ANNOTATE":          false,
+	"ACCOUNTING_DIR":         false,
+	"ACCOUNTING_PERIOD":      false,
+	"ACCOUNTING_MAX_SIZE":    false,
+	"ACCOUNTING_MAX_FILES":   false,
+	"ACCOUNTING_ANNOTATE":    false,
+	"GPU_MEMORY_FACTOR":      false,
 }
 
 func ValidateEnvVars() error {
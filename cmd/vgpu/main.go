@@ -39,6 +39,7 @@ import (
 	"volcano.sh/k8s-device-plugin/pkg/plugin"
 	"volcano.sh/k8s-device-plugin/pkg/rm"
 	"volcano.sh/k8s-device-plugin/pkg/util"
+	"volcano.sh/k8s-device-plugin/pkg/util/client"
 	"volcano.sh/k8s-device-plugin/pkg/watch"
 )
 
@@ -47,6 +48,11 @@ type options struct {
 	configFile      string
 	kubeletSocket   string
 	cdiFeatureFlags cli.StringSlice
+
+	// stop is closed once the plugin exits, and janitorStarted is set once
+	// the janitor runs.
+	stop           chan struct{}
+	janitorStarted bool
 }
 
 func main() {
@@ -141,6 +147,42 @@ func main() {
 			Usage:   "the path where the NVIDIA driver root is mounted in the container; used for generating CDI specifications",
 			EnvVars: []string{"DRIVER_ROOT_CTR_PATH", "CONTAINER_DRIVER_ROOT"},
 		},
+		&cli.StringFlag{
+			Name:    "hook-path",
+			Value:   spec.DefaultHookPath,
+			Usage:   "the host directory holding libvgpu.so and ld.so.preload, under which the vGPU cache directories of containers are created",
+			EnvVars: []string{"HOOK_PATH"},
+		},
+		&cli.StringFlag{
+			Name:    "libvgpu-path",
+			Value:   spec.DefaultLibvgpuPath,
+			Usage:   "the path libvgpu.so is mounted at in containers; it must match the entry of ld.so.preload",
+			EnvVars: []string{"LIBVGPU_PATH"},
+		},
+		&cli.StringFlag{
+			Name:    "vgpu-cache-path",
+			Value:   spec.DefaultVGPUCachePath,
+			Usage:   "the path the vGPU cache directory is mounted at in containers",
+			EnvVars: []string{"VGPU_CACHE_PATH"},
+		},
+		&cli.StringFlag{
+			Name:    "vgpu-lock-path",
+			Value:   spec.DefaultVGPULockPath,
+			Usage:   "the vGPU lock directory shared by all containers, at the same path on the host and in containers",
+			EnvVars: []string{"VGPU_LOCK_PATH"},
+		},
+		&cli.StringFlag{
+			Name:    "memory-advertisement",
+			Value:   spec.MemoryAdvertisementDevices,
+			Usage:   "how the vGPU memory resource is advertised:\n\t\t[devices | node-status]",
+			EnvVars: []string{"MEMORY_ADVERTISEMENT"},
+		},
+		&cli.StringFlag{
+			Name:    "vgpu-isolation",
+			Value:   spec.VGPUIsolationLibvgpu,
+			Usage:   "how the memory and cores of vGPU containers are limited:\n\t\t[libvgpu | mps]",
+			EnvVars: []string{"VGPU_ISOLATION"},
+		},
 		&cli.StringFlag{
 			Name:    "mps-root",
 			Usage:   "the path on the host where MPS-specific mounts and files are created by the MPS control daemon manager",
@@ -162,6 +204,11 @@ func main() {
 			Usage:   "The specified IMEX channels are required",
 			EnvVars: []string{"IMEX_REQUIRED"},
 		},
+		&cli.IntSliceFlag{
+			Name:    "imex-channel-pool",
+			Usage:   "A list of IMEX channels allocated one per pod to the pods asking for one.",
+			EnvVars: []string{"IMEX_CHANNEL_POOL"},
+		},
 		// The following CLI flags do not have equivalents in the config file.
 		&cli.StringFlag{
 			Name:        "kubelet-socket",
@@ -197,6 +244,12 @@ func main() {
 			Usage: "the ratio for NVIDIA device cores scaling",
 			Value: 1.0,
 		},
+		&cli.DurationFlag{
+			Name:    "cache-gc-interval",
+			Usage:   "how often to remove the vGPU cache directories of pods gone from the node; 0 disables it",
+			Value:   5 * time.Minute,
+			EnvVars: []string{"CACHE_GC_INTERVAL"},
+		},
 	}
 	o.flags = c.Flags
 
@@ -247,9 +300,43 @@ func validateFlags(infolib nvinfo.Interface, config *spec.Config) error {
 		return fmt.Errorf("unknown MIG strategy: %v", *config.Flags.MigStrategy)
 	}
 
+	for name, path := range map[string]*string{
+		"--hook-path":       config.Flags.Plugin.HookPath,
+		"--libvgpu-path":    config.Flags.Plugin.LibvgpuPath,
+		"--vgpu-cache-path": config.Flags.Plugin.VGPUCachePath,
+		"--vgpu-lock-path":  config.Flags.Plugin.VGPULockPath,
+	} {
+		if path == nil || !filepath.IsAbs(*path) {
+			return fmt.Errorf("invalid %s option: must be an absolute path", name)
+		}
+	}
+
+	switch *config.Flags.Plugin.MemoryAdvertisement {
+	case spec.MemoryAdvertisementDevices:
+	case spec.MemoryAdvertisementNodeStatus:
+	default:
+		return fmt.Errorf("invalid --memory-advertisement option: %v", *config.Flags.Plugin.MemoryAdvertisement)
+	}
+
+	switch *config.Flags.Plugin.VGPUIsolation {
+	case spec.VGPUIsolationLibvgpu:
+	case spec.VGPUIsolationMPS:
+		if config.Sharing.SharingStrategy() == spec.SharingStrategyMPS {
+			return fmt.Errorf("using --vgpu-isolation=mps is not supported with MPS sharing")
+		}
+		if config.Flags.MpsRoot == nil || *config.Flags.MpsRoot == "" {
+			return fmt.Errorf("using --vgpu-isolation=mps requires --mps-root to be specified")
+		}
+	default:
+		return fmt.Errorf("invalid --vgpu-isolation option: %v", *config.Flags.Plugin.VGPUIsolation)
+	}
+
 	if err := spec.AssertChannelIDsValid(config.Imex.ChannelIDs); err != nil {
 		return fmt.Errorf("invalid IMEX channel IDs: %w", err)
 	}
+	if err := spec.AssertChannelPoolValid(config.Imex.Pool); err != nil {
+		return fmt.Errorf("invalid IMEX channel pool: %w", err)
+	}
 
 	return nil
 }
@@ -267,7 +354,12 @@ func start(c *cli.Context, o *options) error {
 	klog.InfoS(fmt.Sprintf("Starting %s", c.App.Name), "version", c.App.Version)
 
 	klog.Info("Loading NVML")
-	if nvret := config.Nvml().Init(); nvret != nvml.SUCCESS {
+	if nvret := config.Nvml().Init(); nvret == nvml.SUCCESS {
+		defer func() { klog.Info("Shutdown of NVML returned:", config.Nvml().Shutdown()) }()
+	} else if isTegra(c) {
+		// Tegra devices are discovered and registered without NVML.
+		klog.Infof("Failed to initialize NVML: %v; continuing without it on Tegra.", nvret)
+	} else {
 		klog.Infof("Failed to initialize NVML: %v.", nvret)
 		klog.Infof("If this is a GPU node, did you set the docker default runtime to `nvidia`?")
 		klog.Infof("You can check the prerequisites at: https://github.com/NVIDIA/k8s-device-plugin#prerequisites")
@@ -278,7 +370,6 @@ func start(c *cli.Context, o *options) error {
 		}
 		select {}
 	}
-	defer func() { klog.Info("Shutdown of NVML returned:", config.Nvml().Shutdown()) }()
 
 	kubeletSocketDir := filepath.Dir(o.kubeletSocket)
 	klog.Infof("Starting FS watcher for %v", kubeletSocketDir)
@@ -293,6 +384,9 @@ func start(c *cli.Context, o *options) error {
 
 	util.LoadNvidiaConfig(c)
 
+	o.stop = make(chan struct{})
+	defer close(o.stop)
+
 	var started bool
 	var restartTimeout <-chan time.Time
 	var plugins []plugin.Interface
@@ -360,6 +454,35 @@ exit:
 	return nil
 }
 
+// startJanitor starts removing the vGPU cache directories of the pods gone
+// from the node, with the first configuration that was validated.
+func (o *options) startJanitor(c *cli.Context, config *spec.Config) {
+	interval := c.Duration("cache-gc-interval")
+	if o.janitorStarted || interval <= 0 {
+		return
+	}
+	o.janitorStarted = true
+	janitor, err := plugin.NewJanitor(client.GetClient(), os.Getenv("NODE_NAME"), *config.Flags.Plugin.HookPath)
+	if err != nil {
+		klog.Errorf("Not removing stale vGPU cache directories: %v", err)
+		return
+	}
+	go janitor.Run(o.stop, interval)
+}
+
+// isTegra checks whether devices are discovered as Tegra devices, either
+// explicitly or by resolving the platform.
+func isTegra(c *cli.Context) bool {
+	switch strategy := c.String("device-discovery-strategy"); strategy {
+	case "tegra":
+		return true
+	case "", "auto":
+		infolib := nvinfo.New(nvinfo.WithRoot(c.String("driver-root-ctr-path")))
+		return infolib.ResolvePlatform() == nvinfo.PlatformTegra
+	}
+	return false
+}
+
 func startPlugins(c *cli.Context, o *options) ([]plugin.Interface, bool, error) {
 	// Load the configuration file
 	klog.Info("Loading configuration.")
@@ -386,6 +509,7 @@ func startPlugins(c *cli.Context, o *options) ([]plugin.Interface, bool, error)
 	if err != nil {
 		return nil, false, fmt.Errorf("unable to validate flags: %v", err)
 	}
+	o.startJanitor(c, config)
 
 	// Update the configuration file with default resources.
 	klog.Info("Updating config with default resource matching patterns.")
@@ -401,6 +525,13 @@ func startPlugins(c *cli.Context, o *options) ([]plugin.Interface, bool, error)
 	}
 	klog.Infof("\nRunning with config:\n%v", string(configJSON))
 
+	// Without libvgpu the limits of the vGPU resources can not be enforced,
+	// so they are not advertised until it shows up.
+	if err := plugin.CheckLibvgpu(config); err != nil {
+		klog.Errorf("Not advertising vGPU resources: %v", err)
+		return nil, true, nil
+	}
+
 	// Get the set of plugins.
 	klog.Info("Retrieving plugins.")
 	plugins, err := GetPlugins(c.Context, infolib, nvmllib, devicelib, config, o)
@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
@@ -28,6 +29,7 @@ import (
 	"volcano.sh/k8s-device-plugin/pkg/cdi"
 	"volcano.sh/k8s-device-plugin/pkg/imex"
 	"volcano.sh/k8s-device-plugin/pkg/plugin"
+	"volcano.sh/k8s-device-plugin/pkg/util/client"
 )
 
 // GetPlugins returns a set of plugins for the specified configuration.
@@ -44,6 +46,14 @@ func GetPlugins(ctx context.Context, infolib info.Interface, nvmllib nvml.Interf
 	if err != nil {
 		return nil, fmt.Errorf("error querying IMEX channels: %w", err)
 	}
+	imexPoolChannels, err := imex.GetPoolChannels(config, driverRoot.getDevRoot())
+	if err != nil {
+		return nil, fmt.Errorf("error querying IMEX pool channels: %w", err)
+	}
+	var imexPool *imex.Pool
+	if len(imexPoolChannels) > 0 {
+		imexPool = imex.NewPool(imexPoolChannels, client.GetClient(), os.Getenv("NODE_NAME"))
+	}
 
 	cdiHandler, err := cdi.New(infolib, nvmllib, devicelib,
 		cdi.WithDeviceListStrategies(deviceListStrategies),
@@ -57,7 +67,7 @@ func GetPlugins(ctx context.Context, infolib info.Interface, nvmllib nvml.Interf
 		cdi.WithGdrcopyEnabled(*config.Flags.GDRCopyEnabled),
 		cdi.WithGdsEnabled(*config.Flags.GDSEnabled),
 		cdi.WithMofedEnabled(*config.Flags.MOFEDEnabled),
-		cdi.WithImexChannels(imexChannels),
+		cdi.WithImexChannels(append(append(imex.Channels{}, imexChannels...), imexPoolChannels...)),
 		cdi.WithFeatureFlags(o.cdiFeatureFlags.Value()...),
 	)
 	if err != nil {
@@ -70,13 +80,18 @@ func GetPlugins(ctx context.Context, infolib info.Interface, nvmllib nvml.Interf
 		plugin.WithDeviceListStrategies(deviceListStrategies),
 		plugin.WithFailOnInitError(*config.Flags.FailOnInitError),
 		plugin.WithImexChannels(imexChannels),
+		plugin.WithImexPool(imexPool),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create plugins: %w", err)
 	}
 
-	if err := cdiHandler.CreateSpecFile(); err != nil {
-		return nil, fmt.Errorf("unable to create cdi spec file: %v", err)
+	// With volume-mounts alone, the spec is only needed once a MIG reset
+	// changed the devices, and is generated then.
+	if deviceListStrategies.AnyCDIEnabled() {
+		if err := cdiHandler.CreateSpecFile(); err != nil {
+			return nil, fmt.Errorf("unable to create cdi spec file: %v", err)
+		}
 	}
 
 	return plugins, nil
@@ -16,11 +16,16 @@
 
 package cdi
 
+import (
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
 // Interface provides the API to the 'cdi' package
 //
 //go:generate moq -rm -fmt=goimports -stub -out api_mock.go . Interface
 type Interface interface {
 	CreateSpecFile() error
+	CreateVGPUSpecFile(string, specs.ContainerEdits) (string, error)
 	QualifiedName(string, string) string
 	AdditionalDevices() []string
 }
@@ -5,6 +5,8 @@ package cdi
 
 import (
 	"sync"
+
+	"tags.cncf.io/container-device-interface/specs-go"
 )
 
 // Ensure, that InterfaceMock does implement Interface.
@@ -23,6 +25,9 @@ var _ Interface = &InterfaceMock{}
 //			CreateSpecFileFunc: func() error {
 //				panic("mock out the CreateSpecFile method")
 //			},
+//			CreateVGPUSpecFileFunc: func(s string, containerEdits specs.ContainerEdits) (string, error) {
+//				panic("mock out the CreateVGPUSpecFile method")
+//			},
 //			QualifiedNameFunc: func(s1 string, s2 string) string {
 //				panic("mock out the QualifiedName method")
 //			},
@@ -39,6 +44,9 @@ type InterfaceMock struct {
 	// CreateSpecFileFunc mocks the CreateSpecFile method.
 	CreateSpecFileFunc func() error
 
+	// CreateVGPUSpecFileFunc mocks the CreateVGPUSpecFile method.
+	CreateVGPUSpecFileFunc func(s string, containerEdits specs.ContainerEdits) (string, error)
+
 	// QualifiedNameFunc mocks the QualifiedName method.
 	QualifiedNameFunc func(s1 string, s2 string) string
 
@@ -50,6 +58,13 @@ type InterfaceMock struct {
 		// CreateSpecFile holds details about calls to the CreateSpecFile method.
 		CreateSpecFile []struct {
 		}
+		// CreateVGPUSpecFile holds details about calls to the CreateVGPUSpecFile method.
+		CreateVGPUSpecFile []struct {
+			// S is the s argument value.
+			S string
+			// ContainerEdits is the containerEdits argument value.
+			ContainerEdits specs.ContainerEdits
+		}
 		// QualifiedName holds details about calls to the QualifiedName method.
 		QualifiedName []struct {
 			// S1 is the s1 argument value.
@@ -58,9 +73,10 @@ type InterfaceMock struct {
 			S2 string
 		}
 	}
-	lockAdditionalDevices sync.RWMutex
-	lockCreateSpecFile    sync.RWMutex
-	lockQualifiedName     sync.RWMutex
+	lockAdditionalDevices  sync.RWMutex
+	lockCreateSpecFile     sync.RWMutex
+	lockCreateVGPUSpecFile sync.RWMutex
+	lockQualifiedName      sync.RWMutex
 }
 
 // AdditionalDevices calls AdditionalDevicesFunc.
@@ -123,6 +139,46 @@ func (mock *InterfaceMock) CreateSpecFileCalls() []struct {
 	return calls
 }
 
+// CreateVGPUSpecFile calls CreateVGPUSpecFileFunc.
+func (mock *InterfaceMock) CreateVGPUSpecFile(s string, containerEdits specs.ContainerEdits) (string, error) {
+	callInfo := struct {
+		S              string
+		ContainerEdits specs.ContainerEdits
+	}{
+		S:              s,
+		ContainerEdits: containerEdits,
+	}
+	mock.lockCreateVGPUSpecFile.Lock()
+	mock.calls.CreateVGPUSpecFile = append(mock.calls.CreateVGPUSpecFile, callInfo)
+	mock.lockCreateVGPUSpecFile.Unlock()
+	if mock.CreateVGPUSpecFileFunc == nil {
+		var (
+			sOut   string
+			errOut error
+		)
+		return sOut, errOut
+	}
+	return mock.CreateVGPUSpecFileFunc(s, containerEdits)
+}
+
+// CreateVGPUSpecFileCalls gets all the calls that were made to CreateVGPUSpecFile.
+// Check the length with:
+//
+//	len(mockedInterface.CreateVGPUSpecFileCalls())
+func (mock *InterfaceMock) CreateVGPUSpecFileCalls() []struct {
+	S              string
+	ContainerEdits specs.ContainerEdits
+} {
+	var calls []struct {
+		S              string
+		ContainerEdits specs.ContainerEdits
+	}
+	mock.lockCreateVGPUSpecFile.RLock()
+	calls = mock.calls.CreateVGPUSpecFile
+	mock.lockCreateVGPUSpecFile.RUnlock()
+	return calls
+}
+
 // QualifiedName calls QualifiedNameFunc.
 func (mock *InterfaceMock) QualifiedName(s1 string, s2 string) string {
 	callInfo := struct {
@@ -25,12 +25,14 @@ import (
 	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi"
+	nvcdispec "github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/spec"
 	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/transform"
 	transformroot "github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/transform/root"
 	"github.com/sirupsen/logrus"
 	"k8s.io/klog/v2"
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
 	cdiparser "tags.cncf.io/container-device-interface/pkg/parser"
+	"tags.cncf.io/container-device-interface/specs-go"
 
 	spec "volcano.sh/k8s-device-plugin/api/config/v1"
 	"volcano.sh/k8s-device-plugin/pkg/imex"
@@ -83,7 +85,9 @@ func New(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interf
 		opt(c)
 	}
 
-	if !c.deviceListStrategies.AnyCDIEnabled() {
+	// The volume-mounts strategy gets a handler too, to regenerate the specs
+	// after a MIG reset. They are not created for it at startup.
+	if !c.deviceListStrategies.AnyCDIEnabled() && !c.deviceListStrategies.Includes(spec.DeviceListStrategyVolumeMounts) {
 		return &null{}, nil
 	}
 	hasNVML, _ := infolib.HasNvml()
@@ -172,8 +176,15 @@ func New(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interf
 }
 
 // CreateSpecFile creates a CDI spec file for the specified devices.
+// The specs of all classes are generated and validated before any is
+// written, and each is written atomically, so that a failure leaves the
+// previous specs in place.
 func (cdi *cdiHandler) CreateSpecFile() error {
-	var emptySpecs []string
+	type generated struct {
+		class string
+		spec  nvcdispec.Interface
+	}
+	var generatedSpecs []generated
 	for class, cdilib := range cdi.cdilibs {
 		cdi.logger.Infof("Generating CDI spec for resource: %s/%s", cdi.vendor, class)
 
@@ -198,18 +209,28 @@ func (cdi *cdiHandler) CreateSpecFile() error {
 			return fmt.Errorf("failed to transform driver root in CDI spec: %v", err)
 		}
 
-		specName, err := cdiapi.GenerateNameForSpec(spec.Raw())
+		if class == "gpu" {
+			if err := validateGPUSpec(spec.Raw()); err != nil {
+				return fmt.Errorf("invalid CDI spec for resource %s/%s: %v", cdi.vendor, class, err)
+			}
+		}
+		generatedSpecs = append(generatedSpecs, generated{class: class, spec: spec})
+	}
+
+	var emptySpecs []string
+	for _, g := range generatedSpecs {
+		specName, err := cdiapi.GenerateNameForSpec(g.spec.Raw())
 		if err != nil {
 			return fmt.Errorf("failed to generate spec name: %v", err)
 		}
 
-		err = spec.Save(filepath.Join(cdiRoot, specName+".json"))
+		err = g.spec.Save(filepath.Join(cdiRoot, specName+".json"))
 		if err != nil {
 			// TODO: This is a brittle check since it relies on exact string matches.
 			// We should pull this functionality into the CDI tooling instead.
 			if strings.Contains(err.Error(), "invalid device, empty device edits") {
-				klog.ErrorS(err, "Ignoring empty CDI specs", "vendor", cdi.vendor, "class", class)
-				emptySpecs = append(emptySpecs, class)
+				klog.ErrorS(err, "Ignoring empty CDI specs", "vendor", cdi.vendor, "class", g.class)
+				emptySpecs = append(emptySpecs, g.class)
 				continue
 			}
 			return fmt.Errorf("failed to save CDI spec: %v", err)
@@ -224,6 +245,30 @@ func (cdi *cdiHandler) CreateSpecFile() error {
 	return nil
 }
 
+// validateGPUSpec checks that every MIG device of a spec comes with its
+// nvidia-cap device nodes, which are missing when the spec is generated
+// while the MIG devices are still being created.
+func validateGPUSpec(raw *specs.Spec) error {
+	for _, device := range raw.Devices {
+		// MIG devices are named MIG-<uuid> or <gpu>:<mig> depending on the
+		// device ID strategy.
+		if !strings.HasPrefix(device.Name, "MIG-") && !strings.Contains(device.Name, ":") {
+			continue
+		}
+		hasCap := false
+		for _, node := range device.ContainerEdits.DeviceNodes {
+			if strings.Contains(node.Path, "nvidia-cap") {
+				hasCap = true
+				break
+			}
+		}
+		if !hasCap {
+			return fmt.Errorf("MIG device %s does not have a corresponding nvidia-cap device", device.Name)
+		}
+	}
+	return nil
+}
+
 func (cdi *cdiHandler) getRootTransformer() transform.Transformer {
 	driverRootTransformer := transformroot.New(
 		transformroot.WithRoot(cdi.driverRoot),
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cdi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+func TestValidateGPUSpec(t *testing.T) {
+	gpu := specs.Device{
+		Name:           "GPU-0",
+		ContainerEdits: specs.ContainerEdits{DeviceNodes: []*specs.DeviceNode{{Path: "/dev/nvidia0"}}},
+	}
+	mig := func(name string, paths ...string) specs.Device {
+		d := specs.Device{Name: name}
+		for _, path := range paths {
+			d.ContainerEdits.DeviceNodes = append(d.ContainerEdits.DeviceNodes, &specs.DeviceNode{Path: path})
+		}
+		return d
+	}
+
+	testCases := []struct {
+		description string
+		devices     []specs.Device
+		expectError bool
+	}{
+		{
+			description: "full GPUs only",
+			devices:     []specs.Device{gpu},
+		},
+		{
+			description: "MIG device with its caps",
+			devices: []specs.Device{gpu,
+				mig("MIG-0", "/dev/nvidia0", "/dev/nvidia-caps/nvidia-cap21", "/dev/nvidia-caps/nvidia-cap22")},
+		},
+		{
+			description: "MIG device without caps",
+			devices:     []specs.Device{gpu, mig("MIG-0", "/dev/nvidia0")},
+			expectError: true,
+		},
+		{
+			description: "MIG device named by index without caps",
+			devices:     []specs.Device{gpu, mig("0:1", "/dev/nvidia0")},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := validateGPUSpec(&specs.Spec{Kind: "nvidia.com/gpu", Devices: tc.devices})
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVGPUSpecFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"k8s.device-plugin.nvidia.com-vgpu_uid1_main.json",
+		"nvidia.com-vgpu_uid1_main.json",
+		"k8s.device-plugin.nvidia.com-vgpu_uid2_sidecar.json",
+		"k8s.device-plugin.nvidia.com-gpu.json",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("{}"), 0644))
+	}
+
+	files, err := vgpuSpecFiles(root)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"uid1_main": {
+			filepath.Join(root, "k8s.device-plugin.nvidia.com-vgpu_uid1_main.json"),
+			filepath.Join(root, "nvidia.com-vgpu_uid1_main.json"),
+		},
+		"uid2_sidecar": {filepath.Join(root, "k8s.device-plugin.nvidia.com-vgpu_uid2_sidecar.json")},
+	}, files)
+}
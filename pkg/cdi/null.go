@@ -17,7 +17,10 @@
 package cdi
 
 import (
+	"errors"
+
 	"k8s.io/klog/v2"
+	"tags.cncf.io/container-device-interface/specs-go"
 )
 
 type null struct{}
@@ -39,6 +42,12 @@ func (n *null) CreateSpecFile() error {
 	return nil
 }
 
+// CreateVGPUSpecFile returns an error for the null handler, which is only used
+// when CDI is disabled.
+func (n *null) CreateVGPUSpecFile(name string, edits specs.ContainerEdits) (string, error) {
+	return "", errors.New("cannot create a vGPU CDI spec with the null CDI handler")
+}
+
 // QualifiedName is a no-op for the null handler. A error message is logged
 // inidicating this should never be called for the null handler.
 func (n *null) QualifiedName(class string, id string) string {
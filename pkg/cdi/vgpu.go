@@ -0,0 +1,92 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi/spec"
+	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
+	"tags.cncf.io/container-device-interface/specs-go"
+)
+
+// vgpuClass is the class of the devices injecting libvgpu into a single
+// container.
+const vgpuClass = "vgpu"
+
+// CreateVGPUSpecFile writes a transient CDI spec holding a single device,
+// name, that applies edits to the container it is requested for, and returns
+// the qualified name of the device.
+func (cdi *cdiHandler) CreateVGPUSpecFile(name string, edits specs.ContainerEdits) (string, error) {
+	s, err := spec.New(
+		spec.WithDeviceSpecs([]specs.Device{{Name: name, ContainerEdits: edits}}),
+		spec.WithVendor(cdi.vendor),
+		spec.WithClass(vgpuClass),
+		spec.WithPermissions(0644),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create vGPU CDI spec: %v", err)
+	}
+	specName, err := cdiapi.GenerateNameForTransientSpec(s.Raw(), name)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate vGPU CDI spec name: %v", err)
+	}
+	if err := s.Save(filepath.Join(cdiRoot, specName+".json")); err != nil {
+		return "", fmt.Errorf("failed to save vGPU CDI spec: %v", err)
+	}
+	return cdi.QualifiedName(vgpuClass, name), nil
+}
+
+// VGPUSpecFiles returns the paths of the transient CDI specs written by
+// CreateVGPUSpecFile, by the name of their device.
+func VGPUSpecFiles() (map[string][]string, error) {
+	return vgpuSpecFiles(cdiRoot)
+}
+
+func vgpuSpecFiles(root string) (map[string][]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, "*-"+vgpuClass+"_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]string)
+	for _, path := range paths {
+		_, name, ok := strings.Cut(strings.TrimSuffix(filepath.Base(path), ".json"), "-"+vgpuClass+"_")
+		if !ok {
+			continue
+		}
+		files[name] = append(files[name], path)
+	}
+	return files, nil
+}
+
+// RemoveVGPUSpecFiles removes the transient CDI specs written by
+// CreateVGPUSpecFile for name, whatever their vendor.
+func RemoveVGPUSpecFiles(name string) error {
+	paths, err := filepath.Glob(filepath.Join(cdiRoot, "*-"+vgpuClass+"_"+name+".json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -58,9 +58,22 @@ var (
 )
 
 func Nvml() nvml.Interface {
+	lock.Lock()
+	defer lock.Unlock()
+
 	return nvmllib
 }
 
+// SetNvml replaces the NVML library returned by Nvml and used by Device, such
+// as with a fake one in tests.
+func SetNvml(lib nvml.Interface) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	nvmllib = lib
+	globalDevice = nil
+}
+
 func Device() device.Interface {
 	if globalDevice != nil {
 		return globalDevice
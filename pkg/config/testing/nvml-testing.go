@@ -0,0 +1,273 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/NVIDIA/go-nvml/pkg/nvml/mock"
+)
+
+const (
+	defaultGPUName = "NVIDIA A100-SXM4-40GB"
+	// xidFallenOffTheBus is the Xid reported for a GPU that fell off the bus.
+	xidFallenOffTheBus = 79
+)
+
+// GPU describes a GPU of a fake NVML library.
+type GPU struct {
+	UUID string
+	// Name is the product name of the GPU, NVIDIA A100-SXM4-40GB by default.
+	Name string
+	// MemoryMiB is the total memory of the GPU.
+	MemoryMiB uint64
+	// MigDevices are the MIG devices of the GPU. MIG is enabled on a GPU
+	// with any.
+	MigDevices []MigDevice
+}
+
+// MigDevice describes a MIG device of a fake GPU.
+type MigDevice struct {
+	UUID      string
+	MemoryMiB uint64
+}
+
+// Nvml is a fake NVML library serving a fixed set of GPUs, indexed and
+// numbered in the order they are given. Xid events can be raised on them, and
+// they can be lost as if they fell off the bus.
+type Nvml struct {
+	mock.Interface
+
+	gpus   []*mock.Device
+	byUUID map[string]*mock.Device
+	// migUUIDs holds the UUIDs of the MIG devices of the GPUs, by UUID.
+	migUUIDs map[string][]string
+
+	mu sync.Mutex
+	// lost holds the UUIDs of the lost GPUs and of their MIG devices.
+	lost map[string]bool
+	// eventSets holds the event sets the GPUs registered events to.
+	eventSets map[*mock.Device][]chan nvml.EventData
+}
+
+var _ nvml.Interface = (*Nvml)(nil)
+
+// NewNvml creates a fake NVML library serving the GPUs.
+func NewNvml(gpus ...GPU) *Nvml {
+	n := &Nvml{
+		byUUID:    make(map[string]*mock.Device),
+		migUUIDs:  make(map[string][]string),
+		lost:      make(map[string]bool),
+		eventSets: make(map[*mock.Device][]chan nvml.EventData),
+	}
+	for i, gpu := range gpus {
+		n.gpus = append(n.gpus, n.newGPU(i, gpu))
+	}
+
+	n.InitFunc = func() nvml.Return { return nvml.SUCCESS }
+	n.ShutdownFunc = func() nvml.Return { return nvml.SUCCESS }
+	n.ExtensionsFunc = func() nvml.ExtendedInterface {
+		return &mock.ExtendedInterface{
+			LookupSymbolFunc: func(s string) error { return nil },
+		}
+	}
+	n.SystemGetDriverVersionFunc = func() (string, nvml.Return) { return "550.54.15", nvml.SUCCESS }
+	n.SystemGetCudaDriverVersionFunc = func() (int, nvml.Return) { return 12040, nvml.SUCCESS }
+	n.DeviceGetCountFunc = func() (int, nvml.Return) { return len(n.gpus), nvml.SUCCESS }
+	n.DeviceGetHandleByIndexFunc = func(i int) (nvml.Device, nvml.Return) {
+		if i < 0 || i >= len(n.gpus) {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		return n.device(n.gpus[i])
+	}
+	n.DeviceGetHandleByUUIDFunc = func(uuid string) (nvml.Device, nvml.Return) {
+		d, ok := n.byUUID[uuid]
+		if !ok {
+			return nil, nvml.ERROR_NOT_FOUND
+		}
+		return n.device(d)
+	}
+	n.DeviceGetMemoryInfoFunc = func(d nvml.Device) (nvml.Memory, nvml.Return) { return d.GetMemoryInfo() }
+	n.DeviceGetNameFunc = func(d nvml.Device) (string, nvml.Return) { return d.GetName() }
+	n.DeviceGetMigDeviceHandleByIndexFunc = func(d nvml.Device, i int) (nvml.Device, nvml.Return) {
+		return d.GetMigDeviceHandleByIndex(i)
+	}
+	n.EventSetCreateFunc = func() (nvml.EventSet, nvml.Return) { return n.newEventSet(), nvml.SUCCESS }
+	return n
+}
+
+// RaiseXid raises a critical Xid error on the GPU of the UUID, to the event
+// sets it registered events to.
+func (n *Nvml) RaiseXid(uuid string, xid uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	d := n.byUUID[uuid]
+	for _, events := range n.eventSets[d] {
+		e := nvml.EventData{
+			Device:            d,
+			EventType:         nvml.EventTypeXidCriticalError,
+			EventData:         xid,
+			GpuInstanceId:     0xFFFFFFFF,
+			ComputeInstanceId: 0xFFFFFFFF,
+		}
+		// Events are dropped once nothing waits for them anymore.
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// LoseGPU makes the GPU of the UUID and its MIG devices fail every call but
+// GetUUID, and raises the Xid of a GPU fallen off the bus.
+func (n *Nvml) LoseGPU(uuid string) {
+	n.mu.Lock()
+	n.lost[uuid] = true
+	for _, mig := range n.migUUIDs[uuid] {
+		n.lost[mig] = true
+	}
+	n.mu.Unlock()
+
+	n.RaiseXid(uuid, xidFallenOffTheBus)
+}
+
+func (n *Nvml) isLost(uuid string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.lost[uuid]
+}
+
+// device returns a device unless it is lost.
+func (n *Nvml) device(d *mock.Device) (nvml.Device, nvml.Return) {
+	uuid, _ := d.GetUUID()
+	if n.isLost(uuid) {
+		return nil, nvml.ERROR_GPU_IS_LOST
+	}
+	return d, nvml.SUCCESS
+}
+
+func (n *Nvml) newGPU(index int, gpu GPU) *mock.Device {
+	name := gpu.Name
+	if name == "" {
+		name = defaultGPUName
+	}
+	d := &mock.Device{}
+	n.byUUID[gpu.UUID] = d
+
+	var migs []*mock.Device
+	for i, mig := range gpu.MigDevices {
+		migs = append(migs, n.newMigDevice(d, i, mig))
+		n.migUUIDs[gpu.UUID] = append(n.migUUIDs[gpu.UUID], mig.UUID)
+	}
+	migMode := nvml.DEVICE_MIG_DISABLE
+	if len(migs) > 0 {
+		migMode = nvml.DEVICE_MIG_ENABLE
+	}
+
+	// A lost GPU still reports its UUID, so its events can be attributed.
+	d.GetUUIDFunc = func() (string, nvml.Return) { return gpu.UUID, nvml.SUCCESS }
+	d.GetNameFunc = func() (string, nvml.Return) { return name, n.check(gpu.UUID) }
+	d.GetIndexFunc = func() (int, nvml.Return) { return index, n.check(gpu.UUID) }
+	d.GetMinorNumberFunc = func() (int, nvml.Return) { return index, n.check(gpu.UUID) }
+	d.GetMemoryInfoFunc = func() (nvml.Memory, nvml.Return) {
+		total := gpu.MemoryMiB << 20
+		return nvml.Memory{Total: total, Free: total}, n.check(gpu.UUID)
+	}
+	d.GetCudaComputeCapabilityFunc = func() (int, int, nvml.Return) { return 8, 0, n.check(gpu.UUID) }
+	d.GetPciInfoFunc = func() (nvml.PciInfo, nvml.Return) {
+		info := nvml.PciInfo{Bus: uint32(index + 1)}
+		copy(info.BusId[:], fmt.Sprintf("00000000:%02X:00.0", index+1))
+		return info, n.check(gpu.UUID)
+	}
+	d.IsMigDeviceHandleFunc = func() (bool, nvml.Return) { return false, nvml.SUCCESS }
+	d.GetMigModeFunc = func() (int, int, nvml.Return) { return migMode, migMode, n.check(gpu.UUID) }
+	d.GetMaxMigDeviceCountFunc = func() (int, nvml.Return) { return len(migs), nvml.SUCCESS }
+	d.GetMigDeviceHandleByIndexFunc = func(i int) (nvml.Device, nvml.Return) {
+		if i < 0 || i >= len(migs) {
+			return nil, nvml.ERROR_NOT_FOUND
+		}
+		return n.device(migs[i])
+	}
+	d.GetSupportedEventTypesFunc = func() (uint64, nvml.Return) {
+		return nvml.EventTypeXidCriticalError | nvml.EventTypeDoubleBitEccError | nvml.EventTypeSingleBitEccError, n.check(gpu.UUID)
+	}
+	d.RegisterEventsFunc = func(eventTypes uint64, set nvml.EventSet) nvml.Return {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.eventSets[d] = append(n.eventSets[d], set.(*eventSet).events)
+		return nvml.SUCCESS
+	}
+	return d
+}
+
+func (n *Nvml) newMigDevice(parent *mock.Device, index int, mig MigDevice) *mock.Device {
+	d := &mock.Device{}
+	n.byUUID[mig.UUID] = d
+
+	d.GetUUIDFunc = func() (string, nvml.Return) { return mig.UUID, nvml.SUCCESS }
+	d.GetMemoryInfoFunc = func() (nvml.Memory, nvml.Return) {
+		total := mig.MemoryMiB << 20
+		return nvml.Memory{Total: total, Free: total}, n.check(mig.UUID)
+	}
+	d.IsMigDeviceHandleFunc = func() (bool, nvml.Return) { return true, nvml.SUCCESS }
+	d.GetDeviceHandleFromMigDeviceHandleFunc = func() (nvml.Device, nvml.Return) { return n.device(parent) }
+	d.GetGpuInstanceIdFunc = func() (int, nvml.Return) { return index, n.check(mig.UUID) }
+	d.GetComputeInstanceIdFunc = func() (int, nvml.Return) { return 0, n.check(mig.UUID) }
+	return d
+}
+
+// check returns the result of a call to the device of the UUID.
+func (n *Nvml) check(uuid string) nvml.Return {
+	if n.isLost(uuid) {
+		return nvml.ERROR_GPU_IS_LOST
+	}
+	return nvml.SUCCESS
+}
+
+// eventSet is an event set receiving the events raised on a fake GPU.
+type eventSet struct {
+	mock.EventSet
+	events chan nvml.EventData
+}
+
+func (n *Nvml) newEventSet() *eventSet {
+	s := &eventSet{events: make(chan nvml.EventData, 16)}
+	s.WaitFunc = func(timeout uint32) (nvml.EventData, nvml.Return) {
+		select {
+		case e := <-s.events:
+			return e, nvml.SUCCESS
+		case <-time.After(time.Duration(timeout) * time.Millisecond):
+			return nvml.EventData{}, nvml.ERROR_TIMEOUT
+		}
+	}
+	s.FreeFunc = func() nvml.Return {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		for d, sets := range n.eventSets {
+			n.eventSets[d] = slices.DeleteFunc(sets, func(events chan nvml.EventData) bool {
+				return events == s.events
+			})
+		}
+		return nvml.SUCCESS
+	}
+	return s
+}
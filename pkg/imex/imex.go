@@ -40,8 +40,17 @@ type Channel struct {
 // GetChannels returns the set of channels for the given config.
 // If the selection of the default IMEX channel is disabled no channels are returned.
 func GetChannels(config *spec.Config, devRoot string) (Channels, error) {
+	return getChannels(config.Imex.ChannelIDs, config.Imex.Required, devRoot)
+}
+
+// GetPoolChannels returns the set of channels of the pool for the given config.
+func GetPoolChannels(config *spec.Config, devRoot string) (Channels, error) {
+	return getChannels(config.Imex.Pool, config.Imex.Required, devRoot)
+}
+
+func getChannels(channelIDs []int, required bool, devRoot string) (Channels, error) {
 	var channels Channels
-	for _, channelID := range config.Imex.ChannelIDs {
+	for _, channelID := range channelIDs {
 		id := fmt.Sprintf("%d", channelID)
 		channelName := "channel" + id
 		path := filepath.Join("/dev/nvidia-caps-imex-channels", channelName)
@@ -51,7 +60,7 @@ func GetChannels(config *spec.Config, devRoot string) (Channels, error) {
 			HostPath: filepath.Join(devRoot, path),
 		}
 		if exists, err := channel.exists(); !exists {
-			if config.Imex.Required {
+			if required {
 				return nil, errors.Join(err, fmt.Errorf("requested IMEX channel %v does not exist", channelName))
 			}
 			klog.Warningf("Ignoring requested IMEX channel %v (%v)", channelName, err)
@@ -63,6 +72,16 @@ func GetChannels(config *spec.Config, devRoot string) (Channels, error) {
 	return channels, nil
 }
 
+// byID returns the channel with the given ID, or nil.
+func (cs Channels) byID(id string) *Channel {
+	for _, c := range cs {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
 // exists checks whether the IMEX channel exists.
 // We check both the Path and HostPath since the location of the device node
 // associated with the channel in the container is dependent on how it is
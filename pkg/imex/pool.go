@@ -0,0 +1,145 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+// Pool allocates the channels of a pool to the pods of a node asking for one
+// through util.ImexChannelAnnotation. A channel is allocated to the pods
+// recorded with it in util.AssignedImexChannelAnnotation, so it is released
+// once they are deleted or terminated, and the allocations survive restarts
+// of the device plugin.
+type Pool struct {
+	channels Channels
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewPool creates a pool of channels for the pods of the node.
+func NewPool(channels Channels, client kubernetes.Interface, nodeName string) *Pool {
+	return &Pool{
+		channels: channels,
+		client:   client,
+		nodeName: nodeName,
+	}
+}
+
+// Allocate returns the channel of the pod, allocating it and recording it on
+// the pod if it has none yet, or nil if the pod does not ask for one. A
+// channel allocated with "auto" is used by no other pod, while one asked for
+// by ID is shared with the other pods asking for it.
+func (p *Pool) Allocate(pod *corev1.Pod) (*Channel, error) {
+	if id, ok := pod.Annotations[util.AssignedImexChannelAnnotation]; ok {
+		if c := p.channels.byID(id); c != nil {
+			return c, nil
+		}
+		return nil, fmt.Errorf("IMEX channel %s of pod %s/%s is not in the pool", id, pod.Namespace, pod.Name)
+	}
+	request, ok := pod.Annotations[util.ImexChannelAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	inUse, err := p.inUse(pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the IMEX channels in use: %w", err)
+	}
+
+	var channel *Channel
+	if request == util.ImexChannelAuto {
+		for _, c := range p.channels {
+			if _, used := inUse[c.ID]; !used {
+				channel = c
+				break
+			}
+		}
+		if channel == nil {
+			return nil, fmt.Errorf("no free IMEX channel in the pool for pod %s/%s", pod.Namespace, pod.Name)
+		}
+	} else {
+		channel = p.channels.byID(request)
+		if channel == nil {
+			return nil, fmt.Errorf("invalid %s %q: not a channel of the pool", util.ImexChannelAnnotation, request)
+		}
+		if inUse[channel.ID] {
+			return nil, fmt.Errorf("IMEX channel %s is allocated to another pod", channel.ID)
+		}
+	}
+
+	if err := p.record(pod, channel); err != nil {
+		return nil, fmt.Errorf("failed to record IMEX channel %s on pod %s/%s: %w", channel.ID, pod.Namespace, pod.Name, err)
+	}
+	klog.InfoS("Allocated IMEX channel", "pod", klog.KObj(pod), "channel", channel.ID)
+	return channel, nil
+}
+
+// inUse returns the channels allocated to the other pods of the node, and
+// whether each is used by a single pod.
+func (p *Pool) inUse(pod *corev1.Pod) (map[string]bool, error) {
+	pods, err := p.client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", p.nodeName).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	inUse := make(map[string]bool)
+	for _, other := range pods.Items {
+		if other.UID == pod.UID || other.Status.Phase == corev1.PodSucceeded || other.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		id, ok := other.Annotations[util.AssignedImexChannelAnnotation]
+		if !ok {
+			continue
+		}
+		inUse[id] = inUse[id] || other.Annotations[util.ImexChannelAnnotation] == util.ImexChannelAuto
+	}
+	return inUse, nil
+}
+
+// record sets the channel of the pod in util.AssignedImexChannelAnnotation.
+func (p *Pool) record(pod *corev1.Pod, channel *Channel) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{util.AssignedImexChannelAnnotation: channel.ID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = p.client.CoreV1().Pods(pod.Namespace).Patch(context.Background(), pod.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[util.AssignedImexChannelAnnotation] = channel.ID
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package imex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+func newPod(name string, phase corev1.PodPhase, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			UID:         k8stypes.UID(name),
+			Annotations: annotations,
+		},
+		Spec:   corev1.PodSpec{NodeName: "node1"},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestPoolAllocate(t *testing.T) {
+	channels := Channels{{ID: "1"}, {ID: "2"}}
+	auto := func(assigned string) map[string]string {
+		a := map[string]string{util.ImexChannelAnnotation: util.ImexChannelAuto}
+		if assigned != "" {
+			a[util.AssignedImexChannelAnnotation] = assigned
+		}
+		return a
+	}
+	byID := func(id, assigned string) map[string]string {
+		a := map[string]string{util.ImexChannelAnnotation: id}
+		if assigned != "" {
+			a[util.AssignedImexChannelAnnotation] = assigned
+		}
+		return a
+	}
+
+	testCases := []struct {
+		description string
+		others      []*corev1.Pod
+		pod         *corev1.Pod
+		expected    string
+		expectError bool
+	}{
+		{
+			description: "pod not asking for a channel",
+			pod:         newPod("pod", corev1.PodPending, nil),
+		},
+		{
+			description: "first free channel",
+			others:      []*corev1.Pod{newPod("other", corev1.PodRunning, auto("1"))},
+			pod:         newPod("pod", corev1.PodPending, auto("")),
+			expected:    "2",
+		},
+		{
+			description: "channel of a terminated pod is released",
+			others:      []*corev1.Pod{newPod("other", corev1.PodSucceeded, auto("1"))},
+			pod:         newPod("pod", corev1.PodPending, auto("")),
+			expected:    "1",
+		},
+		{
+			description: "no free channel",
+			others: []*corev1.Pod{
+				newPod("other1", corev1.PodRunning, auto("1")),
+				newPod("other2", corev1.PodRunning, byID("2", "2")),
+			},
+			pod:         newPod("pod", corev1.PodPending, auto("")),
+			expectError: true,
+		},
+		{
+			description: "channel shared by the pods of a job",
+			others:      []*corev1.Pod{newPod("other", corev1.PodRunning, byID("2", "2"))},
+			pod:         newPod("pod", corev1.PodPending, byID("2", "")),
+			expected:    "2",
+		},
+		{
+			description: "channel allocated to another pod",
+			others:      []*corev1.Pod{newPod("other", corev1.PodRunning, auto("2"))},
+			pod:         newPod("pod", corev1.PodPending, byID("2", "")),
+			expectError: true,
+		},
+		{
+			description: "channel not in the pool",
+			pod:         newPod("pod", corev1.PodPending, byID("7", "")),
+			expectError: true,
+		},
+		{
+			description: "channel already recorded",
+			pod:         newPod("pod", corev1.PodPending, auto("1")),
+			expected:    "1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tc.pod)
+			for _, other := range tc.others {
+				_, err := client.CoreV1().Pods(other.Namespace).Create(context.Background(), other, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			pool := NewPool(channels, client, "node1")
+
+			channel, err := pool.Allocate(tc.pod)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expected == "" {
+				require.Nil(t, channel)
+				return
+			}
+			require.Equal(t, tc.expected, channel.ID)
+
+			pod, err := client.CoreV1().Pods(tc.pod.Namespace).Get(context.Background(), tc.pod.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, pod.Annotations[util.AssignedImexChannelAnnotation])
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+// freeMemoryStep is the granularity of the free memory label, coarse enough
+// for the label not to change with every pod.
+const freeMemoryStep = 25
+
+// volcanoVGPULabeler generates the labels describing how the GPUs of the node
+// are shared as vGPUs.
+type volcanoVGPULabeler struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewVolcanoVGPULabeler creates a labeler describing how the GPUs of the node
+// are shared as vGPUs, from the device config loaded by
+// util.LoadNvidiaConfig, along with how much of their memory is free. If no
+// device config is loaded no labels are generated. The free memory is read
+// from the API server, and is left out without a client or a node name.
+func NewVolcanoVGPULabeler(client kubernetes.Interface, nodeName string) Labeler {
+	return volcanoVGPULabeler{client: client, nodeName: nodeName}
+}
+
+// Labels generates the vGPU labels for the node. Failing to compute the free
+// memory leaves its label out rather than failing the other labels.
+func (l volcanoVGPULabeler) Labels() (Labels, error) {
+	if config.Mode == "" {
+		return nil, nil
+	}
+	labels := Labels{
+		"volcano.sh/vgpu.mode":          config.Mode,
+		"volcano.sh/vgpu.split-count":   strconv.FormatUint(uint64(config.DeviceSplitCount), 10),
+		"volcano.sh/vgpu.memory-factor": strconv.FormatUint(uint64(config.GPUMemoryFactor), 10),
+		"volcano.sh/vgpu.cores-scaling": strconv.FormatFloat(config.DeviceCoresScaling, 'f', -1, 64),
+	}
+	if l.client == nil || l.nodeName == "" {
+		return labels, nil
+	}
+	free, err := l.freeMemoryPercent()
+	if err != nil {
+		klog.Warningf("Unable to compute the free vGPU memory of node %s: %v", l.nodeName, err)
+		return labels, nil
+	}
+	labels["volcano.sh/vgpu.free-memory-percent"] = strconv.Itoa(free)
+	return labels, nil
+}
+
+// freeMemoryPercent returns the share of the memory of the healthy devices
+// in the registration annotation of the node that is not reserved by its
+// pods, rounded down to freeMemoryStep.
+func (l volcanoVGPULabeler) freeMemoryPercent() (int, error) {
+	node, err := l.client.CoreV1().Nodes().Get(context.Background(), l.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	registered, ok := node.Annotations[util.NodeNvidiaDeviceRegistered]
+	if !ok {
+		return 0, fmt.Errorf("annotation %s not found", util.NodeNvidiaDeviceRegistered)
+	}
+	pods, err := l.client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", l.nodeName).String(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pods on node %s: %w", l.nodeName, err)
+	}
+	return freeMemoryPercent(util.DecodeNodeDevices(registered), pods.Items), nil
+}
+
+func freeMemoryPercent(devices []*util.DeviceInfo, pods []corev1.Pod) int {
+	total := map[string]int64{}
+	var sum int64
+	for _, dev := range devices {
+		if dev.Health {
+			total[dev.Id] = int64(dev.Devmem)
+			sum += int64(dev.Devmem)
+		}
+	}
+	if sum == 0 {
+		return 0
+	}
+	used := int64(0)
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		pd, _ := util.DecodePodDevices(pod.Annotations[util.AssignedIDsAnnotations])
+		for _, cd := range pd {
+			for _, dev := range cd {
+				if _, ok := total[dev.UUID]; ok {
+					used += int64(dev.Usedmem)
+				}
+			}
+		}
+	}
+	free := max(sum-used, 0)
+	return int(free*100/sum) / freeMemoryStep * freeMemoryStep
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+func TestVolcanoVGPULabeler(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+			Annotations: map[string]string{
+				util.NodeNvidiaDeviceRegistered: "GPU-0,10,40000,NVIDIA-A100,true,hami-core:GPU-1,10,40000,NVIDIA-A100,true,hami-core:",
+			},
+		},
+	}
+	pod := func(name, devices string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: map[string]string{util.AssignedIDsAnnotations: devices},
+			},
+			Spec:   corev1.PodSpec{NodeName: "node1"},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	testCases := []struct {
+		description    string
+		mode           string
+		objects        []runtime.Object
+		noClient       bool
+		expectedLabels Labels
+	}{
+		{
+			description: "no device config",
+		},
+		{
+			description: "idle node",
+			mode:        "hami-core",
+			objects:     []runtime.Object{node},
+			expectedLabels: Labels{
+				"volcano.sh/vgpu.mode":                "hami-core",
+				"volcano.sh/vgpu.split-count":         "10",
+				"volcano.sh/vgpu.memory-factor":       "1",
+				"volcano.sh/vgpu.cores-scaling":       "1.5",
+				"volcano.sh/vgpu.free-memory-percent": "100",
+			},
+		},
+		{
+			description: "free memory rounded down, finished pods ignored",
+			mode:        "mig",
+			objects: []runtime.Object{
+				node,
+				pod("running", "GPU-0,NVIDIA,30000,50:;GPU-1,NVIDIA,2000,0:", corev1.PodRunning),
+				pod("done", "GPU-1,NVIDIA,40000,100:", corev1.PodSucceeded),
+			},
+			expectedLabels: Labels{
+				"volcano.sh/vgpu.mode":                "mig",
+				"volcano.sh/vgpu.split-count":         "10",
+				"volcano.sh/vgpu.memory-factor":       "1",
+				"volcano.sh/vgpu.cores-scaling":       "1.5",
+				"volcano.sh/vgpu.free-memory-percent": "50",
+			},
+		},
+		{
+			description: "unregistered node leaves the free memory out",
+			mode:        "hami-core",
+			expectedLabels: Labels{
+				"volcano.sh/vgpu.mode":          "hami-core",
+				"volcano.sh/vgpu.split-count":   "10",
+				"volcano.sh/vgpu.memory-factor": "1",
+				"volcano.sh/vgpu.cores-scaling": "1.5",
+			},
+		},
+		{
+			description: "no client leaves the free memory out",
+			mode:        "hami-core",
+			objects:     []runtime.Object{node},
+			noClient:    true,
+			expectedLabels: Labels{
+				"volcano.sh/vgpu.mode":          "hami-core",
+				"volcano.sh/vgpu.split-count":   "10",
+				"volcano.sh/vgpu.memory-factor": "1",
+				"volcano.sh/vgpu.cores-scaling": "1.5",
+			},
+		},
+	}
+
+	defer func(mode string, splitCount, memoryFactor uint, coresScaling float64) {
+		config.Mode, config.DeviceSplitCount, config.GPUMemoryFactor, config.DeviceCoresScaling = mode, splitCount, memoryFactor, coresScaling
+	}(config.Mode, config.DeviceSplitCount, config.GPUMemoryFactor, config.DeviceCoresScaling)
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config.Mode = tc.mode
+			config.DeviceSplitCount = 10
+			config.GPUMemoryFactor = 1
+			config.DeviceCoresScaling = 1.5
+
+			var client kubernetes.Interface = fake.NewSimpleClientset(tc.objects...)
+			if tc.noClient {
+				client = nil
+			}
+			labels, err := NewVolcanoVGPULabeler(client, "node1").Labels()
+			require.NoError(t, err)
+			require.EqualValues(t, tc.expectedLabels, labels)
+		})
+	}
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package nvidia
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -30,10 +29,17 @@ import (
 
 	v0 "volcano.sh/k8s-device-plugin/pkg/monitor/nvidia/v0"
 	v1 "volcano.sh/k8s-device-plugin/pkg/monitor/nvidia/v1"
+	"volcano.sh/k8s-device-plugin/pkg/watch"
 
+	"github.com/fsnotify/fsnotify"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 )
@@ -58,6 +64,9 @@ type UsageInfo interface {
 	IsValidUUID(idx int) bool
 	DeviceUUID(idx int) string
 	DeviceMemoryLimit(idx int) uint64
+	SetDeviceMemoryLimit(idx int, v uint64)
+	DeviceSmLimit(idx int) uint64
+	SetDeviceSmLimit(idx int, v uint64)
 	LastKernelTime() int64
 	//UsedMemory(idx int) (uint64, error)
 	GetPriority() int
@@ -77,10 +86,34 @@ type ContainerUsage struct {
 type ContainerLister struct {
 	containerPath string
 	containers    map[string]*ContainerUsage
-	mutex         sync.Mutex
-	clientset     *kubernetes.Clientset
+	// pending holds container directories that exist but whose cache file
+	// could not be mapped yet. libvgpu fills the header through its own
+	// mapping after creating the file, which produces no inotify event.
+	pending map[string]struct{}
+	mutex   sync.Mutex
+	// usageLock is held for reading while the mapped caches are read and
+	// for writing while they are unmapped, so a pod deletion cannot pull a
+	// mapping from under a reader.
+	usageLock sync.RWMutex
+	clientset kubernetes.Interface
+	watcher   *fsnotify.Watcher
+
+	informerFactory informers.SharedInformerFactory
+	podLister       listerscorev1.PodLister
 }
 
+const (
+	// pendingRetryInterval is how often directories without a usable cache
+	// file are checked again.
+	pendingRetryInterval = time.Second
+	// resyncInterval is how often the containers directory is fully
+	// reconciled against the pods on the node, in case an event was missed.
+	resyncInterval = 5 * time.Minute
+	// staleGracePeriod protects directories of pods the informer has not
+	// seen yet from being removed by a resync.
+	staleGracePeriod = 300 * time.Second
+)
+
 func NewContainerLister() (*ContainerLister, error) {
 	hookPath, ok := os.LookupEnv("HOOK_PATH")
 	if !ok {
@@ -96,11 +129,33 @@ func NewContainerLister() (*ContainerLister, error) {
 		klog.Errorf("Failed to build clientset: %v", err)
 		return nil, err
 	}
-	return &ContainerLister{
-		containerPath: filepath.Join(hookPath, "containers"),
-		containers:    make(map[string]*ContainerUsage),
-		clientset:     clientset,
-	}, nil
+	return newContainerLister(filepath.Join(hookPath, "containers"), clientset, os.Getenv("NODE_NAME")), nil
+}
+
+func newContainerLister(containerPath string, clientset kubernetes.Interface, nodeName string) *ContainerLister {
+	// Scope the pod informer to this node so the monitor does not cache every
+	// pod in the cluster. NODE_NAME is injected via the Downward API in the
+	// official manifest. Fall back to watching all pods if unset to preserve
+	// backward compatibility with custom deployments.
+	var options []informers.SharedInformerOption
+	if nodeName != "" {
+		options = append(options, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		}))
+	}
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(clientset, time.Hour*1, options...)
+	l := &ContainerLister{
+		containerPath:   containerPath,
+		containers:      make(map[string]*ContainerUsage),
+		pending:         make(map[string]struct{}),
+		clientset:       clientset,
+		informerFactory: informerFactory,
+		podLister:       informerFactory.Core().V1().Pods().Lister(),
+	}
+	_, _ = informerFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: l.onPodDelete,
+	})
+	return l
 }
 
 func (l *ContainerLister) Lock() {
@@ -111,7 +166,15 @@ func (l *ContainerLister) UnLock() {
 	l.mutex.Unlock()
 }
 
-func (l *ContainerLister) ListContainers() map[string]*ContainerUsage {
+// ReadContainers calls f with the containers currently mapped. The caches
+// stay mapped until f returns, so f must not keep references to them.
+func (l *ContainerLister) ReadContainers(f func(map[string]*ContainerUsage)) {
+	l.usageLock.RLock()
+	defer l.usageLock.RUnlock()
+	f(l.listContainers())
+}
+
+func (l *ContainerLister) listContainers() map[string]*ContainerUsage {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 	snapshot := make(map[string]*ContainerUsage, len(l.containers))
@@ -121,26 +184,198 @@ func (l *ContainerLister) ListContainers() map[string]*ContainerUsage {
 	return snapshot
 }
 
-func (l *ContainerLister) Clientset() *kubernetes.Clientset {
+func (l *ContainerLister) Clientset() kubernetes.Interface {
 	return l.clientset
 }
 
-func (l *ContainerLister) Update() error {
-	// Scope pod listing to this node to avoid cluster-wide API calls on every
-	// scrape. NODE_NAME is injected via the Downward API in the official manifest.
-	// Fall back to listing all pods if unset to preserve backward compatibility
-	// with custom deployments that may not have NODE_NAME configured.
-	listOptions := metav1.ListOptions{}
-	if nodeName := os.Getenv("NODE_NAME"); nodeName != "" {
-		listOptions.FieldSelector = "spec.nodeName=" + nodeName
-	}
-	pods, err := l.clientset.CoreV1().Pods("").List(context.Background(), listOptions)
+// PodLister returns the lister of the node-scoped pod informer.
+func (l *ContainerLister) PodLister() listerscorev1.PodLister {
+	return l.podLister
+}
+
+// Run starts the pod informer and watches the containers directory, mapping
+// cache files as they appear and unmapping them when their directory or pod
+// goes away. It blocks until stop is closed.
+func (l *ContainerLister) Run(stop <-chan struct{}) error {
+	if err := os.MkdirAll(l.containerPath, 0777); err != nil {
+		return fmt.Errorf("failed to create %s: %w", l.containerPath, err)
+	}
+	watcher, err := watch.Files(l.containerPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create FS watcher for %s: %w", l.containerPath, err)
+	}
+	defer watcher.Close()
+	l.mutex.Lock()
+	l.watcher = watcher
+	l.mutex.Unlock()
+
+	l.informerFactory.Start(stop)
+	for informerType, synced := range l.informerFactory.WaitForCacheSync(stop) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", informerType)
+		}
+	}
+
+	// Pick up the directories created before the watch was set up.
+	if err := l.Update(); err != nil {
+		klog.Errorf("Failed to update container list: %v", err)
+	}
+
+	retry := time.NewTicker(pendingRetryInterval)
+	defer retry.Stop()
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event := <-watcher.Events:
+			l.handleEvent(event)
+		case err := <-watcher.Errors:
+			klog.Errorf("inotify: %v", err)
+		case <-retry.C:
+			l.retryPending()
+		case <-resync.C:
+			if err := l.Update(); err != nil {
+				klog.Errorf("Failed to update container list: %v", err)
+			}
+		}
+	}
+}
+
+// handleEvent reacts to changes of a container directory or of a file
+// directly inside one.
+func (l *ContainerLister) handleEvent(event fsnotify.Event) {
+	parent := filepath.Dir(event.Name)
+	switch {
+	case parent == l.containerPath:
+		name := filepath.Base(event.Name)
+		if event.Has(fsnotify.Create) {
+			l.addContainer(name)
+		}
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			l.removeContainer(name)
+		}
+	case filepath.Dir(parent) == l.containerPath:
+		if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+			l.addContainer(filepath.Base(parent))
+		}
+	}
+}
+
+// addContainer watches the container directory and maps its cache file if
+// one is ready. Directories without a usable cache file are retried later.
+func (l *ContainerLister) addContainer(name string) {
+	dirName := filepath.Join(l.containerPath, name)
+	podUID, ctrName, ok := strings.Cut(name, "_")
+	if !ok {
+		return
+	}
+	info, err := os.Stat(dirName)
+	if err != nil || !info.IsDir() {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.containers[name]; ok {
+		return
+	}
+	if l.watcher != nil {
+		if err := l.watcher.Add(dirName); err != nil {
+			klog.Errorf("Failed to watch %s: %v", dirName, err)
+		}
+	}
+	usage, err := loadCache(dirName)
+	if err != nil || usage == nil {
+		// no cuInit in container yet, or the shared region is still being
+		// initialized
+		if _, ok := l.pending[name]; !ok && err != nil {
+			klog.Infof("Cache in %s not ready: %v", dirName, err)
+		}
+		l.pending[name] = struct{}{}
+		return
 	}
+	delete(l.pending, name)
+	usage.PodUID = podUID
+	usage.ContainerName = ctrName
+	l.containers[name] = usage
+	klog.Infof("Adding ctr dirname %s in monitorpath", dirName)
+}
 
+// removeContainer unmaps the cache of a container and forgets about it.
+func (l *ContainerLister) removeContainer(name string) {
+	l.usageLock.Lock()
+	defer l.usageLock.Unlock()
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
+	delete(l.pending, name)
+	c, ok := l.containers[name]
+	if !ok {
+		return
+	}
+	klog.Infof("Removing ctr dirname %s in monitorpath", filepath.Join(l.containerPath, name))
+	_ = syscall.Munmap(c.data)
+	delete(l.containers, name)
+}
+
+func (l *ContainerLister) retryPending() {
+	l.mutex.Lock()
+	names := make([]string, 0, len(l.pending))
+	for name := range l.pending {
+		names = append(names, name)
+	}
+	l.mutex.Unlock()
+	for _, name := range names {
+		l.addContainer(name)
+	}
+}
+
+func (l *ContainerLister) onPodDelete(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if pod, ok = tombstone.Obj.(*corev1.Pod); !ok {
+			return
+		}
+	}
+	l.removePod(string(pod.UID))
+}
+
+// removePod unmaps and deletes the directories of all containers of a pod.
+func (l *ContainerLister) removePod(podUID string) {
+	entries, err := os.ReadDir(l.containerPath)
+	if err != nil {
+		klog.Errorf("Failed to read %s: %v", l.containerPath, err)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), podUID+"_") {
+			continue
+		}
+		l.removeContainer(entry.Name())
+		dirName := filepath.Join(l.containerPath, entry.Name())
+		klog.Infof("Removing dirname %s in monitorpath", dirName)
+		_ = os.RemoveAll(dirName)
+	}
+}
+
+// Update reconciles the containers directory against the pods known to the
+// informer. Directories of pods that no longer exist are removed once they
+// are older than staleGracePeriod.
+func (l *ContainerLister) Update() error {
+	pods, err := l.podLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	podUIDs := make(map[string]struct{}, len(pods))
+	for _, pod := range pods {
+		podUIDs[string(pod.UID)] = struct{}{}
+	}
+
 	entries, err := os.ReadDir(l.containerPath)
 	if err != nil {
 		return err
@@ -150,41 +385,24 @@ func (l *ContainerLister) Update() error {
 			continue
 		}
 		dirName := filepath.Join(l.containerPath, entry.Name())
-		if !isValidPod(entry.Name(), pods) {
+		podUID, _, _ := strings.Cut(entry.Name(), "_")
+		if _, ok := podUIDs[podUID]; !ok {
 			dirInfo, err := os.Stat(dirName)
-			if err == nil && dirInfo.ModTime().Add(time.Second*300).After(time.Now()) {
+			if err == nil && dirInfo.ModTime().Add(staleGracePeriod).After(time.Now()) {
 				continue
 			}
+			l.removeContainer(entry.Name())
 			klog.Infof("Removing dirname %s in monitorpath", dirName)
-			if c, ok := l.containers[entry.Name()]; ok {
-				syscall.Munmap(c.data)
-				delete(l.containers, entry.Name())
-			}
 			_ = os.RemoveAll(dirName)
 			continue
 		}
-		if _, ok := l.containers[entry.Name()]; ok {
-			continue
-		}
-		usage, err := loadCache(dirName)
-		if err != nil {
-			klog.Errorf("Failed to load cache: %s, error: %v", dirName, err)
-			continue
-		}
-		if usage == nil {
-			// no cuInit in container
-			continue
-		}
-		usage.PodUID = strings.Split(entry.Name(), "_")[0]
-		usage.ContainerName = strings.Split(entry.Name(), "_")[1]
-		l.containers[entry.Name()] = usage
-		klog.Infof("Adding ctr dirname %s in monitorpath", dirName)
+		l.addContainer(entry.Name())
 	}
 	return nil
 }
 
 func loadCache(fpath string) (*ContainerUsage, error) {
-	klog.Infof("Checking path %s", fpath)
+	klog.V(4).Infof("Checking path %s", fpath)
 	files, err := os.ReadDir(fpath)
 	if err != nil {
 		return nil, err
@@ -207,7 +425,7 @@ func loadCache(fpath string) (*ContainerUsage, error) {
 		break
 	}
 	if cacheFile == "" {
-		klog.Infof("No cache file in %s", fpath)
+		klog.V(4).Infof("No cache file in %s", fpath)
 		return nil, nil
 	}
 	info, err := os.Stat(cacheFile)
@@ -247,12 +465,3 @@ func loadCache(fpath string) (*ContainerUsage, error) {
 	}
 	return usage, nil
 }
-
-func isValidPod(name string, pods *corev1.PodList) bool {
-	for _, val := range pods.Items {
-		if strings.Contains(name, string(val.UID)) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,163 @@
+/*
+Copyright 2026 The HAMi Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nvidia
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// writeCache creates a v1 shared region file the way libvgpu does: the file
+// is created first and the header is filled in afterwards.
+func writeCache(t *testing.T, dir string, initialized bool) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, "test.cache"))
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, f.Truncate(4<<20))
+	if !initialized {
+		return
+	}
+	header := make([]byte, 12)
+	binary.NativeEndian.PutUint32(header[0:], SharedRegionMagicFlag)
+	binary.NativeEndian.PutUint32(header[4:], 1)
+	_, err = f.WriteAt(header, 0)
+	require.NoError(t, err)
+}
+
+func newTestPod(uid string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-" + uid,
+			Namespace: "default",
+			UID:       types.UID(uid),
+		},
+		Spec: corev1.PodSpec{NodeName: "node1"},
+	}
+}
+
+func TestContainerListerPendingCache(t *testing.T) {
+	root := t.TempDir()
+	l := newContainerLister(root, fake.NewSimpleClientset(), "node1")
+
+	dir := filepath.Join(root, "uid1_ctr")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	l.addContainer("uid1_ctr")
+	require.Empty(t, l.listContainers())
+	require.Contains(t, l.pending, "uid1_ctr")
+
+	writeCache(t, dir, false)
+	l.retryPending()
+	require.Empty(t, l.listContainers())
+
+	writeCache(t, dir, true)
+	l.retryPending()
+	containers := l.listContainers()
+	require.Contains(t, containers, "uid1_ctr")
+	require.Equal(t, "uid1", containers["uid1_ctr"].PodUID)
+	require.Equal(t, "ctr", containers["uid1_ctr"].ContainerName)
+	require.Empty(t, l.pending)
+
+	l.removeContainer("uid1_ctr")
+	require.Empty(t, l.listContainers())
+}
+
+func TestContainerListerRemoveWaitsForReaders(t *testing.T) {
+	root := t.TempDir()
+	l := newContainerLister(root, fake.NewSimpleClientset(), "node1")
+	dir := filepath.Join(root, "uid1_ctr")
+	require.NoError(t, os.Mkdir(dir, 0777))
+	writeCache(t, dir, true)
+	l.addContainer("uid1_ctr")
+
+	removed := make(chan struct{})
+	l.ReadContainers(func(containers map[string]*ContainerUsage) {
+		require.Contains(t, containers, "uid1_ctr")
+		go func() {
+			l.removeContainer("uid1_ctr")
+			close(removed)
+		}()
+		select {
+		case <-removed:
+			t.Fatal("cache unmapped while it was being read")
+		case <-time.After(100 * time.Millisecond):
+		}
+		require.Zero(t, containers["uid1_ctr"].Info.DeviceNum())
+	})
+	<-removed
+	require.Empty(t, l.listContainers())
+}
+
+func TestContainerListerRun(t *testing.T) {
+	root := t.TempDir()
+	client := fake.NewSimpleClientset(newTestPod("uid1"), newTestPod("uid2"))
+	l := newContainerLister(root, client, "node1")
+
+	// A directory present before the watch starts is picked up by the
+	// initial reconcile.
+	existing := filepath.Join(root, "uid1_ctr")
+	require.NoError(t, os.Mkdir(existing, 0777))
+	writeCache(t, existing, true)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- l.Run(stop)
+	}()
+
+	require.Eventually(t, func() bool {
+		_, ok := l.listContainers()["uid1_ctr"]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	created := filepath.Join(root, "uid2_ctr")
+	require.NoError(t, os.Mkdir(created, 0777))
+	writeCache(t, created, true)
+	require.Eventually(t, func() bool {
+		_, ok := l.listContainers()["uid2_ctr"]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.RemoveAll(created))
+	require.Eventually(t, func() bool {
+		_, ok := l.listContainers()["uid2_ctr"]
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	err := client.CoreV1().Pods("default").Delete(t.Context(), "pod-uid1", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, statErr := os.Stat(existing)
+		return len(l.listContainers()) == 0 && os.IsNotExist(statErr)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("Run returned early: %v", err)
+	default:
+	}
+}
@@ -141,6 +141,18 @@ func (s Spec) DeviceMemoryLimit(idx int) uint64 {
 	return s.sr.limit[idx]
 }
 
+func (s Spec) SetDeviceMemoryLimit(idx int, v uint64) {
+	s.sr.limit[idx] = v
+}
+
+func (s Spec) DeviceSmLimit(idx int) uint64 {
+	return s.sr.smLimit[idx]
+}
+
+func (s Spec) SetDeviceSmLimit(idx int, v uint64) {
+	s.sr.smLimit[idx] = v
+}
+
 func (s Spec) LastKernelTime() int64 {
 	return 0
 }
@@ -53,6 +53,9 @@ type Daemon struct {
 	root Root
 	// logTailer tails the MPS control daemon logs.
 	logTailer *tailer
+	// clientLimits is set when every client sets its own memory and thread
+	// limits, in which case no default limits are set for the devices.
+	clientLimits bool
 }
 
 // NewDaemon creates an MPS daemon instance.
@@ -63,6 +66,17 @@ func NewDaemon(rm rm.ResourceManager, root Root) *Daemon {
 	}
 }
 
+// NewClientLimitedDaemon creates an MPS daemon instance whose clients set
+// their own limits, such as vGPU containers limited to what the scheduler
+// assigned them.
+func NewClientLimitedDaemon(rm rm.ResourceManager, root Root) *Daemon {
+	return &Daemon{
+		rm:           rm,
+		root:         root,
+		clientLimits: true,
+	}
+}
+
 // Devices returns the list of devices under the control of this MPS daemon.
 func (d *Daemon) Devices() rm.Devices {
 	return d.rm.Devices()
@@ -116,17 +130,8 @@ func (d *Daemon) Start() error {
 		return err
 	}
 
-	for index, limit := range d.perDevicePinnedDeviceMemoryLimits() {
-		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_device_pinned_mem_limit %s %s", index, limit))
-		if err != nil {
-			return fmt.Errorf("error setting pinned memory limit for device %v: %w", index, err)
-		}
-	}
-	if threadPercentage := d.activeThreadPercentage(); threadPercentage != "" {
-		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_active_thread_percentage %s", threadPercentage))
-		if err != nil {
-			return fmt.Errorf("error setting active thread percentage: %w", err)
-		}
+	if err := d.setDefaultLimits(); err != nil {
+		return err
 	}
 
 	statusFile, err := os.Create(d.startedFile())
@@ -144,6 +149,40 @@ func (d *Daemon) Start() error {
 	return nil
 }
 
+// setDefaultLimits sets the memory and thread limits of the clients not
+// setting their own, splitting the devices evenly between their replicas.
+func (d *Daemon) setDefaultLimits() error {
+	if d.clientLimits {
+		return nil
+	}
+	for index, limit := range d.perDevicePinnedDeviceMemoryLimits() {
+		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_device_pinned_mem_limit %s %s", index, limit))
+		if err != nil {
+			return fmt.Errorf("error setting pinned memory limit for device %v: %w", index, err)
+		}
+	}
+	if threadPercentage := d.activeThreadPercentage(); threadPercentage != "" {
+		_, err := d.EchoPipeToControl(fmt.Sprintf("set_default_active_thread_percentage %s", threadPercentage))
+		if err != nil {
+			return fmt.Errorf("error setting active thread percentage: %w", err)
+		}
+	}
+	return nil
+}
+
+// Restart quits the MPS daemon, if it still answers, and starts it again.
+func (d *Daemon) Restart() error {
+	if _, err := d.EchoPipeToControl("quit"); err != nil {
+		klog.InfoS("MPS daemon did not quit, starting it anyway", "resource", d.rm.Resource(), "error", err)
+	}
+	if d.logTailer != nil {
+		if err := d.logTailer.Stop(); err != nil {
+			klog.InfoS("Stopped log tailer", "resource", d.rm.Resource(), "error", err)
+		}
+	}
+	return d.Start()
+}
+
 func setSELinuxContext(path string, context string) error {
 	_, err := os.Stat("/sys/fs/selinux")
 	if err != nil && errors.Is(err, os.ErrNotExist) {
@@ -44,6 +44,7 @@ type options struct {
 	deviceListStrategies spec.DeviceListStrategies
 
 	imexChannels imex.Channels
+	imexPool     *imex.Pool
 }
 
 // New a new set of plugins with the supplied options.
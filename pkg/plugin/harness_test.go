@@ -0,0 +1,493 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvlib/pkg/nvlib/info"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
+
+	spec "volcano.sh/k8s-device-plugin/api/config/v1"
+	"volcano.sh/k8s-device-plugin/pkg/cdi"
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	ct "volcano.sh/k8s-device-plugin/pkg/config/testing"
+	"volcano.sh/k8s-device-plugin/pkg/rm"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+	"volcano.sh/k8s-device-plugin/pkg/util/client"
+)
+
+const harnessNode = "node1"
+
+// fakeKubelet serves the registration service of the kubelet, and connects to
+// the device plugins registering with it the way the kubelet does.
+type fakeKubelet struct {
+	pluginapi.UnimplementedRegistrationServer
+
+	dir    string
+	server *grpc.Server
+
+	mu      sync.Mutex
+	conns   []*grpc.ClientConn
+	plugins map[string]pluginapi.DevicePluginClient
+}
+
+func newFakeKubelet(t *testing.T, dir string) *fakeKubelet {
+	k := &fakeKubelet{
+		dir:     dir,
+		server:  grpc.NewServer(),
+		plugins: make(map[string]pluginapi.DevicePluginClient),
+	}
+	pluginapi.RegisterRegistrationServer(k.server, k)
+
+	sock, err := (&net.ListenConfig{}).Listen(context.Background(), "unix", k.socket())
+	require.NoError(t, err)
+	go func() { _ = k.server.Serve(sock) }()
+	t.Cleanup(func() {
+		k.server.Stop()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		for _, conn := range k.conns {
+			conn.Close()
+		}
+	})
+	return k
+}
+
+func (k *fakeKubelet) socket() string {
+	return filepath.Join(k.dir, "kubelet.sock")
+}
+
+// Register connects to the endpoint of the device plugin in the directory of
+// the kubelet socket.
+func (k *fakeKubelet) Register(ctx context.Context, r *pluginapi.RegisterRequest) (*pluginapi.Empty, error) {
+	if r.Version != pluginapi.Version {
+		return nil, fmt.Errorf("unsupported version %q", r.Version)
+	}
+	conn, err := grpc.NewClient("unix://"+filepath.Join(k.dir, r.Endpoint),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.conns = append(k.conns, conn)
+	k.plugins[r.ResourceName] = pluginapi.NewDevicePluginClient(conn)
+	return &pluginapi.Empty{}, nil
+}
+
+// resources returns the names of the registered resources.
+func (k *fakeKubelet) resources() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var names []string
+	for name := range k.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (k *fakeKubelet) plugin(t *testing.T, resource string) pluginapi.DevicePluginClient {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	p, ok := k.plugins[resource]
+	require.True(t, ok, "%s is not registered", resource)
+	return p
+}
+
+// harness runs the device plugins of a node with fake GPUs, a fake kubelet and
+// a fake API server.
+type harness struct {
+	nvml    *ct.Nvml
+	client  *fake.Clientset
+	kubelet *fakeKubelet
+}
+
+// newHarness starts the device plugins for the GPUs, and registers them with
+// the fake kubelet. The plugins are stopped when the test ends.
+func newHarness(t *testing.T, gpus ...ct.GPU) *harness {
+	return newHarnessWithOptions(t, nil, gpus...)
+}
+
+// newHarnessWithOptions starts the device plugins like newHarness, with the
+// options overriding the defaults of the harness.
+func newHarnessWithOptions(t *testing.T, opts []Option, gpus ...ct.GPU) *harness {
+	name, mem, cores, percentage, priority := util.ResourceName, util.ResourceMem, util.ResourceCores, util.ResourceMemPercentage, util.ResourcePriority
+	mode, factor, count, scaling := config.Mode, config.GPUMemoryFactor, config.DeviceSplitCount, config.DeviceCoresScaling
+	nvmllib, node := config.Nvml(), *nodeName
+	t.Cleanup(func() {
+		util.ResourceName, util.ResourceMem, util.ResourceCores = name, mem, cores
+		util.ResourceMemPercentage, util.ResourcePriority = percentage, priority
+		config.Mode, config.GPUMemoryFactor, config.DeviceSplitCount, config.DeviceCoresScaling = mode, factor, count, scaling
+		config.SetNvml(nvmllib)
+		*nodeName = node
+	})
+
+	util.ResourceName = "volcano.sh/vgpu-number"
+	util.ResourceMem = "volcano.sh/vgpu-memory"
+	util.ResourceCores = "volcano.sh/vgpu-cores"
+	util.ResourceMemPercentage = "volcano.sh/vgpu-memory-percentage"
+	util.ResourcePriority = ""
+	config.Mode = "hami-core"
+	config.GPUMemoryFactor = 1
+	config.DeviceSplitCount = 10
+	config.DeviceCoresScaling = 1
+
+	h := &harness{
+		nvml: ct.NewNvml(gpus...),
+		client: fake.NewSimpleClientset(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: harnessNode},
+		}),
+	}
+	config.SetNvml(h.nvml)
+	client.SetClient(h.client)
+	*nodeName = harnessNode
+	t.Setenv("NODE_NAME", harnessNode)
+
+	// Unix socket paths are limited to about 100 bytes, which the temporary
+	// directories of tests with long names exceed.
+	dir, err := os.MkdirTemp("", "dp")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	h.kubelet = newFakeKubelet(t, dir)
+
+	hostDir := t.TempDir()
+	cfg := &spec.Config{
+		Flags: spec.Flags{
+			CommandLineFlags: spec.CommandLineFlags{
+				MigStrategy:             ptr(spec.MigStrategyNone),
+				FailOnInitError:         ptr(true),
+				DeviceDiscoveryStrategy: ptr("nvml"),
+				Plugin: &spec.PluginCommandLineFlags{
+					DeviceIDStrategy:    ptr(spec.DeviceIDStrategyUUID),
+					CDIAnnotationPrefix: ptr("cdi.k8s.io/"),
+					HookPath:            ptr(filepath.Join(hostDir, "vgpu")),
+					LibvgpuPath:         ptr("/usr/local/vgpu/libvgpu.so"),
+					VGPUCachePath:       ptr("/tmp/vgpu"),
+					VGPULockPath:        ptr(filepath.Join(hostDir, "vgpulock")),
+				},
+			},
+		},
+	}
+	infolib := info.New(info.WithPlatform(info.PlatformNVML))
+	devicelib := device.New(h.nvml)
+	require.NoError(t, rm.AddDefaultResourcesToConfig(infolib, h.nvml, devicelib, cfg))
+
+	opts = append([]Option{
+		WithConfig(cfg),
+		WithDeviceListStrategies(spec.DeviceListStrategies{spec.DeviceListStrategyEnvVar: true}),
+		WithFailOnInitError(true),
+	}, opts...)
+	plugins, err := New(context.Background(), infolib, h.nvml, devicelib, opts...)
+	require.NoError(t, err)
+	require.NotEmpty(t, plugins)
+	for _, p := range plugins {
+		plugin := p.(*nvidiaDevicePlugin)
+		plugin.socket = filepath.Join(dir, filepath.Base(plugin.socket))
+		require.NoError(t, plugin.Start(h.kubelet.socket()))
+		t.Cleanup(func() { require.NoError(t, plugin.Stop()) })
+	}
+	return h
+}
+
+// listAndWatch opens the device list stream of a resource, and returns the
+// lists it receives.
+func (h *harness) listAndWatch(t *testing.T, resource string) <-chan []*pluginapi.Device {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	stream, err := h.kubelet.plugin(t, resource).ListAndWatch(ctx, &pluginapi.Empty{})
+	require.NoError(t, err)
+
+	lists := make(chan []*pluginapi.Device)
+	go func() {
+		defer close(lists)
+		for {
+			r, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case lists <- r.Devices:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lists
+}
+
+func receive(t *testing.T, lists <-chan []*pluginapi.Device) []*pluginapi.Device {
+	select {
+	case devices, ok := <-lists:
+		require.True(t, ok, "device list stream closed")
+		return devices
+	case <-time.After(10 * time.Second):
+		t.Fatal("no device list received")
+		return nil
+	}
+}
+
+// healthByGPU returns the health of the devices of a list by GPU, requiring
+// all the devices of a GPU to share it.
+func healthByGPU(t *testing.T, devices []*pluginapi.Device) map[string]string {
+	health := make(map[string]string)
+	for _, d := range devices {
+		gpu := d.ID[:strings.LastIndex(d.ID, "-")]
+		if h, ok := health[gpu]; ok {
+			require.Equal(t, h, d.Health, "health of %s", d.ID)
+		}
+		health[gpu] = d.Health
+	}
+	return health
+}
+
+func TestHarnessRegister(t *testing.T) {
+	h := newHarness(t,
+		ct.GPU{UUID: "GPU-0", MemoryMiB: 1024},
+		ct.GPU{UUID: "GPU-1", MemoryMiB: 2048},
+	)
+
+	require.Equal(t, []string{
+		"volcano.sh/vgpu-cores",
+		"volcano.sh/vgpu-memory",
+		"volcano.sh/vgpu-memory-percentage",
+		"volcano.sh/vgpu-number",
+	}, h.kubelet.resources())
+
+	devices := receive(t, h.listAndWatch(t, util.ResourceName))
+	require.Len(t, devices, 20)
+	require.Equal(t, map[string]string{"GPU-0": pluginapi.Healthy, "GPU-1": pluginapi.Healthy}, healthByGPU(t, devices))
+
+	devices = receive(t, h.listAndWatch(t, util.ResourceMem))
+	require.Len(t, devices, 1024+2048)
+
+	require.Eventually(t, func() bool {
+		node, err := h.client.CoreV1().Nodes().Get(context.Background(), harnessNode, metav1.GetOptions{})
+		require.NoError(t, err)
+		return node.Annotations[util.NodeNvidiaDeviceRegistered] ==
+			"GPU-0,10,1024,NVIDIA-NVIDIA A100-SXM4-40GB,true,hami-core:"+
+				"GPU-1,10,2048,NVIDIA-NVIDIA A100-SXM4-40GB,true,hami-core:"
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestHarnessLostGPU(t *testing.T) {
+	h := newHarness(t,
+		ct.GPU{UUID: "GPU-0", MemoryMiB: 1024},
+		ct.GPU{UUID: "GPU-1", MemoryMiB: 1024},
+	)
+
+	lists := h.listAndWatch(t, util.ResourceName)
+	devices := receive(t, lists)
+	require.Equal(t, map[string]string{"GPU-0": pluginapi.Healthy, "GPU-1": pluginapi.Healthy}, healthByGPU(t, devices))
+
+	h.nvml.LoseGPU("GPU-1")
+	devices = receive(t, lists)
+	require.Equal(t, map[string]string{"GPU-0": pluginapi.Healthy, "GPU-1": pluginapi.Unhealthy}, healthByGPU(t, devices))
+}
+
+func TestHarnessAllocate(t *testing.T) {
+	h := newHarness(t,
+		ct.GPU{UUID: "GPU-0", MemoryMiB: 1024},
+		ct.GPU{UUID: "GPU-1", MemoryMiB: 1024},
+	)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+			UID:       "uid1",
+			Annotations: map[string]string{
+				util.AssignedNodeAnnotations:          harnessNode,
+				util.AssignedTimeAnnotations:          "1",
+				util.AssignedIDsToAllocateAnnotations: "GPU-1,NVIDIA,512,30:",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   harnessNode,
+			Containers: []corev1.Container{{Name: "ctr"}},
+		},
+	}
+	_, err := h.client.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// A request of a number of devices no pending pod was assigned fails.
+	_, err = h.kubelet.plugin(t, util.ResourceName).Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIds: []string{"GPU-0-0", "GPU-1-0"}}},
+	})
+	require.Error(t, err)
+
+	// The vGPU resources other than the number of devices get no response
+	// of their own.
+	response, err := h.kubelet.plugin(t, util.ResourceCores).Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIds: []string{"GPU-1-core-0"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.ContainerResponses, 1)
+	require.Empty(t, response.ContainerResponses[0].Envs)
+
+	response, err = h.kubelet.plugin(t, util.ResourceName).Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIds: []string{"GPU-0-3"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.ContainerResponses, 1)
+	envs := response.ContainerResponses[0].Envs
+	require.Equal(t, "GPU-1", envs[deviceListEnvVar])
+	require.Equal(t, "512m", envs["CUDA_DEVICE_MEMORY_LIMIT_0"])
+	require.Equal(t, "30", envs["CUDA_DEVICE_SM_LIMIT"])
+	require.Contains(t, envs, "CUDA_DEVICE_MEMORY_SHARED_CACHE")
+
+	allocated, err := h.client.CoreV1().Pods("default").Get(context.Background(), "pod1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, allocated.Annotations[util.AssignedIDsToAllocateAnnotations])
+	require.Equal(t, util.DeviceBindSuccess, allocated.Annotations[util.DeviceBindPhase])
+}
+
+func TestHarnessAllocateMemoryPercentage(t *testing.T) {
+	h := newHarness(t, ct.GPU{UUID: "GPU-0", MemoryMiB: 1024})
+	// The scheduler reserved 300 MiB for the 25% the container asked for,
+	// rather than the 256 MiB a quarter of the GPU is.
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "default",
+			UID:       "uid1",
+			Annotations: map[string]string{
+				util.AssignedNodeAnnotations:          harnessNode,
+				util.AssignedTimeAnnotations:          "1",
+				util.AssignedIDsToAllocateAnnotations: "GPU-0,NVIDIA,300,0:",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: harnessNode,
+			Containers: []corev1.Container{{
+				Name: "ctr",
+				Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+					"volcano.sh/vgpu-number":            resource.MustParse("1"),
+					"volcano.sh/vgpu-memory-percentage": resource.MustParse("25"),
+				}},
+			}},
+		},
+	}
+	_, err := h.client.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	response, err := h.kubelet.plugin(t, util.ResourceName).Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIds: []string{"GPU-0-0"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.ContainerResponses, 1)
+	require.Equal(t, "300m", response.ContainerResponses[0].Envs["CUDA_DEVICE_MEMORY_LIMIT_0"])
+}
+
+func TestHarnessAllocateCDI(t *testing.T) {
+	testCases := []struct {
+		description  string
+		strategies   []string
+		expectLimits bool
+	}{
+		{
+			description: "vGPU CDI device with CDI strategies only",
+			strategies:  []string{spec.DeviceListStrategyCDIAnnotations},
+		},
+		{
+			description:  "limits in the response with a non-CDI strategy",
+			strategies:   []string{spec.DeviceListStrategyEnvVar, spec.DeviceListStrategyCDIAnnotations},
+			expectLimits: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			strategies, err := spec.NewDeviceListStrategies(tc.strategies)
+			require.NoError(t, err)
+			handler := &cdi.InterfaceMock{
+				AdditionalDevicesFunc: func() []string { return nil },
+				QualifiedNameFunc: func(c string, s string) string {
+					return "nvidia.com/" + c + "=" + s
+				},
+				CreateVGPUSpecFileFunc: func(name string, edits cdispecs.ContainerEdits) (string, error) {
+					return "nvidia.com/vgpu=" + name, nil
+				},
+			}
+			h := newHarnessWithOptions(t, []Option{WithDeviceListStrategies(strategies), WithCDIHandler(handler)},
+				ct.GPU{UUID: "GPU-0", MemoryMiB: 1024},
+			)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod1",
+					Namespace: "default",
+					UID:       "uid1",
+					Annotations: map[string]string{
+						util.AssignedNodeAnnotations:          harnessNode,
+						util.AssignedTimeAnnotations:          "1",
+						util.AssignedIDsToAllocateAnnotations: "GPU-0,NVIDIA,512,30:",
+					},
+				},
+				Spec: corev1.PodSpec{
+					NodeName:   harnessNode,
+					Containers: []corev1.Container{{Name: "ctr"}},
+				},
+			}
+			_, err = h.client.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			response, err := h.kubelet.plugin(t, util.ResourceName).Allocate(context.Background(), &pluginapi.AllocateRequest{
+				ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIds: []string{"GPU-0-0"}}},
+			})
+			require.NoError(t, err)
+			require.Len(t, response.ContainerResponses, 1)
+			r := response.ContainerResponses[0]
+
+			var devices []string
+			for _, v := range r.Annotations {
+				devices = append(devices, strings.Split(v, ",")...)
+			}
+			require.Contains(t, devices, "nvidia.com/gpu=GPU-0")
+			if tc.expectLimits {
+				require.Equal(t, "GPU-0", r.Envs[deviceListEnvVar])
+				require.Equal(t, "512m", r.Envs["CUDA_DEVICE_MEMORY_LIMIT_0"])
+				require.NotEmpty(t, r.Mounts)
+				require.Empty(t, handler.CreateVGPUSpecFileCalls())
+				return
+			}
+			require.NotContains(t, r.Envs, "CUDA_DEVICE_MEMORY_LIMIT_0")
+			require.Empty(t, r.Mounts)
+			require.Contains(t, devices, "nvidia.com/vgpu=uid1_ctr")
+		})
+	}
+}
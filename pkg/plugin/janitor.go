@@ -0,0 +1,262 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"volcano.sh/k8s-device-plugin/pkg/cdi"
+)
+
+// janitorGracePeriod is how old an entry must be before the janitor
+// considers it, so that it does not race with Allocate.
+const janitorGracePeriod = 5 * time.Minute
+
+// Janitor removes the cache directories and CDI specs Allocate creates for
+// containers once their pod is gone from the node. Entries still open or
+// mapped by a process are left alone. The CDI specs are removed whether or not
+// the cache directory is still there, since the monitor removes the
+// directories of deleted pods itself. The lock files are shared by every
+// libvgpu process on the node and are never removed: a process may open one
+// between the check and the removal, and lock a file the next one no longer
+// sees.
+type Janitor struct {
+	nodeName  string
+	cacheRoot string
+	client    kubernetes.Interface
+	// procRoot is where the processes are inspected, the host /proc when the
+	// plugin runs with hostPID.
+	procRoot string
+	// specFiles lists the CDI specs of the containers by container name,
+	// and removeSpecs removes them.
+	specFiles   func() (map[string][]string, error)
+	removeSpecs func(name string) error
+	now         func() time.Time
+}
+
+// NewJanitor returns a janitor for the cache directories under hookPath. The
+// node name is required, as every entry would look stale without the pods of
+// the node.
+func NewJanitor(client kubernetes.Interface, nodeName, hookPath string) (*Janitor, error) {
+	if nodeName == "" {
+		return nil, errors.New("node name is not set")
+	}
+	return &Janitor{
+		nodeName:    nodeName,
+		cacheRoot:   containerCacheRoot(hookPath),
+		client:      client,
+		procRoot:    "/proc",
+		specFiles:   cdi.VGPUSpecFiles,
+		removeSpecs: cdi.RemoveVGPUSpecFiles,
+		now:         time.Now,
+	}, nil
+}
+
+// Run cleans up every interval until stop is closed.
+func (j *Janitor) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			removed, err := j.Clean()
+			if err != nil {
+				klog.Errorf("Failed to clean up vGPU cache directories: %v", err)
+			}
+			if len(removed) > 0 {
+				klog.Infof("Removed %d stale vGPU cache entries", len(removed))
+			}
+		}
+	}
+}
+
+// Clean removes the stale cache directories and CDI specs and returns their
+// paths.
+func (j *Janitor) Clean() ([]string, error) {
+	pods, err := j.client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", j.nodeName).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on node %s: %w", j.nodeName, err)
+	}
+	running := make(map[string]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		running[string(pod.UID)] = true
+	}
+
+	inUse, err := j.filesInUse()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	entries, err := os.ReadDir(j.cacheRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		podUID, _, ok := strings.Cut(entry.Name(), "_")
+		if !entry.IsDir() || !ok || running[podUID] || !j.expired(entry) {
+			continue
+		}
+		dir := filepath.Join(j.cacheRoot, entry.Name())
+		if path, ok := anyInUse(dir, inUse); ok {
+			klog.Warningf("Keeping cache directory %s of deleted pod %s, %s is still in use", dir, podUID, path)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			klog.Errorf("Failed to remove cache directory %s: %v", dir, err)
+			continue
+		}
+		klog.Infof("Removed cache directory %s of deleted pod %s", dir, podUID)
+		removed = append(removed, dir)
+	}
+
+	specs, err := j.specFiles()
+	if err != nil {
+		return removed, fmt.Errorf("failed to list vGPU CDI specs: %w", err)
+	}
+	for name, paths := range specs {
+		podUID, _, _ := strings.Cut(name, "_")
+		if running[podUID] || !j.allExpired(paths) {
+			continue
+		}
+		if err := j.removeSpecs(name); err != nil {
+			klog.Errorf("Failed to remove vGPU CDI specs of deleted pod %s: %v", podUID, err)
+			continue
+		}
+		klog.Infof("Removed vGPU CDI specs of deleted pod %s: %v", podUID, paths)
+		removed = append(removed, paths...)
+	}
+	return removed, nil
+}
+
+func (j *Janitor) expired(entry os.DirEntry) bool {
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	return j.now().Sub(info.ModTime()) > janitorGracePeriod
+}
+
+// allExpired reports whether every file of paths is old enough to be
+// considered.
+func (j *Janitor) allExpired(paths []string) bool {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || j.now().Sub(info.ModTime()) <= janitorGracePeriod {
+			return false
+		}
+	}
+	return true
+}
+
+// fileID identifies a file across mount namespaces.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// filesInUse returns the files open or mapped by any process.
+func (j *Janitor) filesInUse() (map[fileID]bool, error) {
+	procs, err := os.ReadDir(j.procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	inUse := map[fileID]bool{}
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil {
+			continue
+		}
+		// Processes come and go while they are inspected: errors only mean
+		// there is nothing left to look at.
+		dir := filepath.Join(j.procRoot, proc.Name())
+		fds, _ := os.ReadDir(filepath.Join(dir, "fd"))
+		for _, fd := range fds {
+			var st syscall.Stat_t
+			if err := syscall.Stat(filepath.Join(dir, "fd", fd.Name()), &st); err == nil {
+				inUse[fileID{dev: uint64(st.Dev), ino: st.Ino}] = true
+			}
+		}
+		readMaps(filepath.Join(dir, "maps"), inUse)
+	}
+	return inUse, nil
+}
+
+// readMaps adds the files mapped in a /proc/<pid>/maps file. Each line reads
+// "address perms offset major:minor inode path".
+func readMaps(path string, inUse map[fileID]bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		ino, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil || ino == 0 {
+			continue
+		}
+		major, minor, ok := strings.Cut(fields[3], ":")
+		if !ok {
+			continue
+		}
+		maj, err1 := strconv.ParseUint(major, 16, 32)
+		min, err2 := strconv.ParseUint(minor, 16, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		inUse[fileID{dev: unix.Mkdev(uint32(maj), uint32(min)), ino: ino}] = true
+	}
+}
+
+// anyInUse reports the first file under root that is in use.
+func anyInUse(root string, inUse map[fileID]bool) (string, bool) {
+	var found string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || found != "" {
+			return nil
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && inUse[fileID{dev: uint64(st.Dev), ino: st.Ino}] {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, found != ""
+}
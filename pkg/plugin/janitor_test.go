@@ -0,0 +1,107 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestJanitorClean(t *testing.T) {
+	root := t.TempDir()
+	hookPath := filepath.Join(root, "hook")
+	procRoot := filepath.Join(root, "proc")
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default", UID: types.UID("running")},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	})
+	_, err := NewJanitor(client, "", hookPath)
+	require.Error(t, err)
+	j, err := NewJanitor(client, "node1", hookPath)
+	require.NoError(t, err)
+	j.procRoot = procRoot
+	specRoot := filepath.Join(root, "cdi")
+	require.NoError(t, os.MkdirAll(specRoot, 0755))
+	specs := map[string][]string{}
+	mkSpec := func(name string, mtime time.Time) string {
+		path := filepath.Join(specRoot, "k8s.device-plugin.nvidia.com-vgpu_"+name+".json")
+		require.NoError(t, os.WriteFile(path, []byte("{}"), 0644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+		specs[name] = append(specs[name], path)
+		return path
+	}
+	j.specFiles = func() (map[string][]string, error) { return specs, nil }
+	var removedSpecs []string
+	j.removeSpecs = func(name string) error {
+		removedSpecs = append(removedSpecs, name)
+		return nil
+	}
+
+	mkCache := func(name string, mtime time.Time) string {
+		dir := filepath.Join(containerCacheRoot(hookPath), name)
+		require.NoError(t, os.MkdirAll(dir, 0777))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "x.cache"), []byte("cache"), 0666))
+		require.NoError(t, os.Chtimes(dir, mtime, mtime))
+		return dir
+	}
+	running := mkCache("running_main", old)
+	stale := mkCache("deleted_main", old)
+	recent := mkCache("creating_main", now)
+	mapped := mkCache("mapped_main", old)
+	opened := mkCache("opened_main", old)
+	mkSpec("running_main", old)
+	staleSpec := mkSpec("deleted_main", old)
+	// The monitor already removed the cache directory of this pod.
+	orphanSpec := mkSpec("gone_main", old)
+	mkSpec("creating_main", now)
+
+	// Process 1 maps the cache of a deleted pod, process 2 holds a cache
+	// open.
+	var st syscall.Stat_t
+	require.NoError(t, syscall.Stat(filepath.Join(mapped, "x.cache"), &st))
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "1"), 0755))
+	maps := fmt.Sprintf("7f0000000000-7f0000400000 rw-s 00000000 %x:%x %d /tmp/vgpu/x.cache\n",
+		unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev)), st.Ino)
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "1", "maps"), []byte(maps), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "2", "fd"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(opened, "x.cache"), filepath.Join(procRoot, "2", "fd", "3")))
+
+	removed, err := j.Clean()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{stale, staleSpec, orphanSpec}, removed)
+	require.ElementsMatch(t, []string{"deleted_main", "gone_main"}, removedSpecs)
+	for _, path := range []string{running, recent, mapped, opened} {
+		_, err := os.Stat(path)
+		require.NoError(t, err, path)
+	}
+	_, err = os.Stat(stale)
+	require.True(t, os.IsNotExist(err))
+}
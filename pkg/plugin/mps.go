@@ -19,6 +19,7 @@ package plugin
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"k8s.io/klog/v2"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
@@ -26,18 +27,45 @@ import (
 	spec "volcano.sh/k8s-device-plugin/api/config/v1"
 	"volcano.sh/k8s-device-plugin/pkg/mps"
 	"volcano.sh/k8s-device-plugin/pkg/rm"
+	"volcano.sh/k8s-device-plugin/pkg/util"
 )
 
+// mpsHealthCheckInterval is how often the health of the MPS daemon is
+// checked.
+const mpsHealthCheckInterval = 10 * time.Second
+
+// mpsRestartBackoff and mpsMaxRestartBackoff bound the time between two
+// restarts of an unhealthy MPS daemon, which doubles after each failure.
+var (
+	mpsRestartBackoff    = time.Second
+	mpsMaxRestartBackoff = 5 * time.Minute
+)
+
+// mpsDaemon is the part of mps.Daemon used by the plugin.
+type mpsDaemon interface {
+	Start() error
+	Restart() error
+	AssertHealthy() error
+	PipeDir() string
+	ShmDir() string
+}
+
 type mpsOptions struct {
 	enabled      bool
 	resourceName spec.ResourceName
-	daemon       *mps.Daemon
+	daemon       mpsDaemon
 	hostRoot     mps.Root
+	// managed is set when the plugin starts and stops the daemon itself
+	// rather than waiting for one started by the MPS control daemon manager.
+	managed bool
 }
 
 // getMPSOptions returns the MPS options specified for the resource manager.
 // If MPS is not configured and empty set of options is returned.
 func (o *options) getMPSOptions(resourceManager rm.ResourceManager) (mpsOptions, error) {
+	if o.config.Flags.Plugin.VGPUIsolatedByMPS() {
+		return o.getVGPUMPSOptions(resourceManager)
+	}
 	if o.config.Sharing.SharingStrategy() != spec.SharingStrategyMPS {
 		return mpsOptions{}, nil
 	}
@@ -58,6 +86,47 @@ func (o *options) getMPSOptions(resourceManager rm.ResourceManager) (mpsOptions,
 	return m, nil
 }
 
+// getVGPUMPSOptions returns the MPS options of the vGPU resource when its
+// containers are limited as MPS clients. The daemon is run by the plugin, and
+// the containers set their own limits from what the scheduler assigned them.
+func (o *options) getVGPUMPSOptions(resourceManager rm.ResourceManager) (mpsOptions, error) {
+	if resourceManager.Resource() != spec.ResourceName(util.ResourceName) {
+		return mpsOptions{}, nil
+	}
+	for _, device := range resourceManager.Devices() {
+		if device.IsMigDevice() {
+			return mpsOptions{}, errors.New("vGPU isolation using MPS is not supported for MIG devices")
+		}
+	}
+
+	m := mpsOptions{
+		enabled:      true,
+		resourceName: resourceManager.Resource(),
+		daemon:       mps.NewClientLimitedDaemon(resourceManager, mps.ContainerRoot),
+		hostRoot:     mps.Root(*o.config.Flags.MpsRoot),
+		managed:      true,
+	}
+	return m, nil
+}
+
+// startDaemon starts the daemon if it is managed by the plugin and not
+// already running. The daemon is never stopped by the plugin: it outlives
+// plugin restarts so that the running clients keep their GPUs, and a daemon
+// left by a previous plugin is reused as long as it answers.
+func (m *mpsOptions) startDaemon() error {
+	if m == nil || !m.enabled || !m.managed {
+		return nil
+	}
+	if err := m.daemon.AssertHealthy(); err == nil {
+		klog.InfoS("Reusing running MPS daemon", "resource", m.resourceName)
+		return nil
+	}
+	if err := m.daemon.Start(); err != nil {
+		return fmt.Errorf("error starting MPS daemon: %w", err)
+	}
+	return nil
+}
+
 func (m *mpsOptions) waitForDaemon() error {
 	if m == nil || !m.enabled {
 		return nil
@@ -71,6 +140,73 @@ func (m *mpsOptions) waitForDaemon() error {
 	return nil
 }
 
+// supervise checks the health of the daemon every interval until stop is
+// closed. When it stops answering, the devices are sent to unhealthy and, if
+// the plugin manages the daemon, it is restarted with an exponential backoff.
+// Once it answers again, the devices it sent to unhealthy are sent to
+// healthy. The plugin keeps those the resource manager reported unhealthy,
+// before or during the outage, unhealthy.
+func (m *mpsOptions) supervise(stop <-chan interface{}, interval time.Duration, devices rm.Devices, unhealthy, healthy chan<- *rm.Device) {
+	if m == nil || !m.enabled {
+		return
+	}
+	failed := false
+	backoff := mpsRestartBackoff
+	wait := interval
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+
+		err := m.daemon.AssertHealthy()
+		if err == nil {
+			if failed {
+				klog.InfoS("MPS daemon is healthy again", "resource", m.resourceName)
+				if !sendDevices(stop, healthy, devices) {
+					return
+				}
+			}
+			failed = false
+			backoff = mpsRestartBackoff
+			wait = interval
+			continue
+		}
+
+		klog.ErrorS(err, "MPS daemon is unhealthy", "resource", m.resourceName)
+		if !failed {
+			failed = true
+			if !sendDevices(stop, unhealthy, devices) {
+				return
+			}
+		}
+		if !m.managed {
+			continue
+		}
+		if err := m.daemon.Restart(); err != nil {
+			klog.ErrorS(err, "Failed to restart MPS daemon", "resource", m.resourceName, "retryIn", backoff)
+		} else {
+			klog.InfoS("Restarted MPS daemon", "resource", m.resourceName)
+		}
+		wait = backoff
+		backoff = min(2*backoff, mpsMaxRestartBackoff)
+	}
+}
+
+// sendDevices sends every device to ch, and reports whether it did before
+// stop was closed.
+func sendDevices(stop <-chan interface{}, ch chan<- *rm.Device, devices rm.Devices) bool {
+	for _, d := range devices {
+		select {
+		case <-stop:
+			return false
+		case ch <- d:
+		}
+	}
+	return true
+}
+
 func (m *mpsOptions) updateReponse(response *pluginapi.ContainerAllocateResponse) {
 	if m == nil || !m.enabled {
 		return
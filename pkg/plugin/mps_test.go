@@ -0,0 +1,235 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	spec "volcano.sh/k8s-device-plugin/api/config/v1"
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/rm"
+)
+
+// fakeMPSDaemon fails its health checks until it is restarted restartsToHeal
+// times, or forever when it is negative.
+type fakeMPSDaemon struct {
+	sync.Mutex
+	restartsToHeal int
+	restarts       int
+	starts         int
+}
+
+func (d *fakeMPSDaemon) Start() error      { d.Lock(); defer d.Unlock(); d.starts++; return nil }
+func (d *fakeMPSDaemon) PipeDir() string   { return "/mps/pipe" }
+func (d *fakeMPSDaemon) ShmDir() string    { return "/dev/shm" }
+func (d *fakeMPSDaemon) Restart() error    { d.Lock(); defer d.Unlock(); d.restarts++; return nil }
+func (d *fakeMPSDaemon) restartCount() int { d.Lock(); defer d.Unlock(); return d.restarts }
+
+func (d *fakeMPSDaemon) AssertHealthy() error {
+	d.Lock()
+	defer d.Unlock()
+	if d.restartsToHeal < 0 || d.restarts < d.restartsToHeal {
+		return errors.New("no MPS daemon")
+	}
+	return nil
+}
+
+func TestSuperviseMPSDaemon(t *testing.T) {
+	defer func(backoff time.Duration) { mpsRestartBackoff = backoff }(mpsRestartBackoff)
+	mpsRestartBackoff = time.Millisecond
+
+	devices := rm.Devices{
+		"GPU-0::0": {Device: pluginapi.Device{ID: "GPU-0::0", Health: pluginapi.Healthy}},
+		"GPU-0::1": {Device: pluginapi.Device{ID: "GPU-0::1", Health: pluginapi.Healthy}},
+	}
+
+	testCases := []struct {
+		description    string
+		managed        bool
+		restartsToHeal int
+		expectHealthy  bool
+	}{
+		{
+			description:    "managed daemon is restarted",
+			managed:        true,
+			restartsToHeal: 2,
+			expectHealthy:  true,
+		},
+		{
+			description:    "external daemon is not restarted",
+			restartsToHeal: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			daemon := &fakeMPSDaemon{restartsToHeal: tc.restartsToHeal}
+			m := &mpsOptions{enabled: true, daemon: daemon, managed: tc.managed}
+
+			stop := make(chan interface{})
+			unhealthy := make(chan *rm.Device)
+			healthy := make(chan *rm.Device)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				m.supervise(stop, time.Millisecond, devices, unhealthy, healthy)
+			}()
+
+			receive := func(ch <-chan *rm.Device) []string {
+				var ids []string
+				for range devices {
+					select {
+					case d := <-ch:
+						ids = append(ids, d.ID)
+					case <-time.After(5 * time.Second):
+						t.Fatal("timed out waiting for devices")
+					}
+				}
+				return ids
+			}
+			require.ElementsMatch(t, []string{"GPU-0::0", "GPU-0::1"}, receive(unhealthy))
+			if tc.expectHealthy {
+				require.ElementsMatch(t, []string{"GPU-0::0", "GPU-0::1"}, receive(healthy))
+				require.Equal(t, tc.restartsToHeal, daemon.restartCount())
+			}
+
+			close(stop)
+			<-done
+			if !tc.managed {
+				require.Zero(t, daemon.restartCount())
+			}
+		})
+	}
+}
+
+// fakeListAndWatchServer passes the device lists it is sent to lists.
+type fakeListAndWatchServer struct {
+	grpc.ServerStream
+	ctx   context.Context
+	lists chan []*pluginapi.Device
+}
+
+func (s *fakeListAndWatchServer) Context() context.Context { return s.ctx }
+
+func (s *fakeListAndWatchServer) Send(r *pluginapi.ListAndWatchResponse) error {
+	s.lists <- r.Devices
+	return nil
+}
+
+func TestListAndWatchMPSRecovery(t *testing.T) {
+	defer func(count uint) { config.DeviceSplitCount = count }(config.DeviceSplitCount)
+	config.DeviceSplitCount = 1
+
+	devices := rm.Devices{
+		"GPU-0": {Device: pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy}, TotalMemory: 1 << 30},
+		"GPU-1": {Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy}, TotalMemory: 1 << 30},
+	}
+	plugin := &nvidiaDevicePlugin{
+		rm: &rm.ResourceManagerMock{
+			ResourceFunc: func() spec.ResourceName { return "nvidia.com/gpu" },
+			DevicesFunc:  func() rm.Devices { return devices },
+		},
+	}
+	plugin.initialize()
+	defer plugin.cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &fakeListAndWatchServer{ctx: ctx, lists: make(chan []*pluginapi.Device)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, plugin.ListAndWatch(&pluginapi.Empty{}, server))
+	}()
+	defer func() { cancel(); <-done }()
+
+	health := func() map[string]string {
+		res := make(map[string]string)
+		for _, d := range <-server.lists {
+			res[d.ID] = d.Health
+		}
+		return res
+	}
+	require.Equal(t, map[string]string{"GPU-0-0": pluginapi.Healthy, "GPU-1-0": pluginapi.Healthy}, health())
+
+	// The daemon goes down, and the resource manager finds an XID on GPU-1
+	// during the outage.
+	plugin.mpsUnhealthy <- devices["GPU-0"]
+	health()
+	plugin.mpsUnhealthy <- devices["GPU-1"]
+	health()
+	plugin.health <- devices["GPU-1"]
+	health()
+
+	plugin.healthy <- devices["GPU-0"]
+	require.Equal(t, map[string]string{"GPU-0-0": pluginapi.Healthy, "GPU-1-0": pluginapi.Unhealthy}, health())
+	plugin.healthy <- devices["GPU-1"]
+	select {
+	case list := <-server.lists:
+		t.Fatalf("unexpected device list %v", list)
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, pluginapi.Unhealthy, devices["GPU-1"].Health)
+
+	// The resource manager finds GPU-1 healthy again, but not GPU-0 while
+	// its daemon is down.
+	plugin.recovered <- devices["GPU-1"]
+	require.Equal(t, map[string]string{"GPU-0-0": pluginapi.Healthy, "GPU-1-0": pluginapi.Healthy}, health())
+	plugin.mpsUnhealthy <- devices["GPU-0"]
+	health()
+	plugin.recovered <- devices["GPU-0"]
+	select {
+	case list := <-server.lists:
+		t.Fatalf("unexpected device list %v", list)
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, pluginapi.Unhealthy, devices["GPU-0"].Health)
+}
+
+func TestStartMPSDaemon(t *testing.T) {
+	testCases := []struct {
+		description    string
+		restartsToHeal int
+		expectStarts   int
+	}{
+		{
+			description:    "running daemon is reused",
+			restartsToHeal: 0,
+			expectStarts:   0,
+		},
+		{
+			description:    "missing daemon is started",
+			restartsToHeal: -1,
+			expectStarts:   1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			daemon := &fakeMPSDaemon{restartsToHeal: tc.restartsToHeal}
+			m := &mpsOptions{enabled: true, managed: true, daemon: daemon}
+			require.NoError(t, m.startDaemon())
+			require.Equal(t, tc.expectStarts, daemon.starts)
+		})
+	}
+}
@@ -76,3 +76,10 @@ func WithImexChannels(imexChannels imex.Channels) Option {
 		m.imexChannels = imexChannels
 	}
 }
+
+// WithImexPool sets the pool of imex channels allocated to pods.
+func WithImexPool(imexPool *imex.Pool) Option {
+	return func(m *options) {
+		m.imexPool = imexPool
+	}
+}
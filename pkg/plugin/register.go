@@ -17,25 +17,33 @@ limitations under the License.
 package plugin
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
-	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/rm"
 	"volcano.sh/k8s-device-plugin/pkg/util"
+	"volcano.sh/k8s-device-plugin/pkg/util/client"
 )
 
 var (
 	nodeName = flag.String("node_name", os.Getenv("NODE_NAME"), "node name")
 )
 
-func RegisterInAnnotation(devs []*pluginapi.Device) error {
+func RegisterInAnnotation(devs rm.Devices) error {
 	devices := ConvertDeviceInfo(devs)
 	annos := make(map[string]string)
 	node, err := util.GetNode(*nodeName)
@@ -65,46 +73,104 @@ func RegisterInAnnotation(devs []*pluginapi.Device) error {
 	return err
 }
 
-func ConvertDeviceInfo(devs []*pluginapi.Device) *[]*util.DeviceInfo {
-	res := make([]*util.DeviceInfo, 0, len(devs))
-	for _, dev := range devs {
-		ndev, ret := config.Nvml().DeviceGetHandleByUUID(dev.ID)
-		if ret != nvml.SUCCESS {
-			fmt.Println("nvml new device by uuid error id=", dev.ID)
-			panic(ret)
+// priorityCapacity is the node capacity of util.ResourcePriority. A priority
+// is a level rather than an amount, so it must never run out.
+const priorityCapacity = math.MaxInt32
+
+// RegisterCapacity advertises in the node status the extended resources no
+// device plugin serves: util.ResourcePriority when it is configured, and
+// util.ResourceMem when memory is advertised there. Kubelet admits pods
+// against these totals, while the scheduler places memory on devices from the
+// registration annotation.
+func RegisterCapacity(devs rm.Devices, memory bool) error {
+	capacity := nodeCapacity(devs, memory)
+	if len(capacity) == 0 {
+		return nil
+	}
+	return patchNodeCapacity(client.GetClient(), *nodeName, capacity)
+}
+
+func nodeCapacity(devs rm.Devices, memory bool) map[string]int64 {
+	capacity := map[string]int64{}
+	if util.ResourcePriority != "" {
+		capacity[util.ResourcePriority] = priorityCapacity
+	}
+	if memory {
+		capacity[util.ResourceMem] = memoryCapacity(*ConvertDeviceInfo(devs))
+	}
+	return capacity
+}
+
+// memoryCapacity is the memory of the healthy devices.
+func memoryCapacity(devices []*util.DeviceInfo) int64 {
+	var total int64
+	for _, dev := range devices {
+		if dev.Health {
+			total += int64(dev.Devmem)
 		}
+	}
+	return total
+}
 
-		memory, ret := config.Nvml().DeviceGetMemoryInfo(ndev)
-		if ret != nvml.SUCCESS {
-			fmt.Println("failed to get memory info for device id=", dev.ID)
-			panic(ret)
+func patchNodeCapacity(kubeClient kubernetes.Interface, name string, capacity map[string]int64) error {
+	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	changed := map[string]string{}
+	for resource, value := range capacity {
+		if current, ok := node.Status.Capacity[corev1.ResourceName(resource)]; ok && current.Value() == value {
+			continue
 		}
+		changed[resource] = strconv.FormatInt(value, 10)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]any{"status": map[string]any{"capacity": changed}})
+	if err != nil {
+		return err
+	}
+	_, err = kubeClient.CoreV1().Nodes().Patch(context.Background(), name, k8stypes.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("failed to patch capacity of node %s: %w", name, err)
+	}
+	klog.Infof("Advertised %v on node %s", changed, name)
+	return nil
+}
 
-		model, ret := config.Nvml().DeviceGetName(ndev)
-		if ret != nvml.SUCCESS {
-			fmt.Println("failed to get model name for device id=", dev.ID)
-			panic(ret)
+// ConvertDeviceInfo converts the devices of the resource manager to the
+// devices registered for the scheduler, ordered by minor number. It relies on
+// rm.Device rather than NVML, so that it works on every platform the resource
+// manager supports. Devices the memory of which is unknown are left out, and
+// reported unhealthy to kubelet by apiDevices.
+func ConvertDeviceInfo(devs rm.Devices) *[]*util.DeviceInfo {
+	res := make([]*util.DeviceInfo, 0, len(devs))
+	seen := make(map[string]bool)
+	for _, dev := range devs {
+		uuid := dev.GetUUID()
+		if seen[uuid] {
+			continue
 		}
+		seen[uuid] = true
 
-		klog.V(3).Infoln("nvml registered device id=", dev.ID, "memory=", memory.Total, "type=", model)
+		if dev.TotalMemory == 0 {
+			klog.Warningf("Unknown memory for device id=%s, not registering it", uuid)
+			continue
+		}
+		klog.V(3).Infoln("registered device id=", uuid, "memory=", dev.TotalMemory, "type=", dev.Model)
 
-		registeredmem := int32(memory.Total/(1024*1024)) / int32(config.GPUMemoryFactor)
+		registeredmem := int32(registeredMemory(dev))
 		klog.V(3).Infoln("GPUMemoryFactor=", config.GPUMemoryFactor, "registeredmem=", registeredmem)
 
-		minor, ret := ndev.GetMinorNumber()
-		if ret != nvml.SUCCESS {
-			klog.Warningf("failed to get minor number for device id=%s, setting to -1", dev.ID)
-			minor = -1
-		}
-
 		res = append(res, &util.DeviceInfo{
-			Id:     dev.ID,
+			Id:     uuid,
 			Count:  int32(config.DeviceSplitCount),
 			Devmem: registeredmem,
 			Mode:   config.Mode,
-			Type:   fmt.Sprintf("%v-%v", "NVIDIA", model),
+			Type:   fmt.Sprintf("%v-%v", "NVIDIA", dev.Model),
 			Health: strings.EqualFold(dev.Health, "healthy"),
-			Minor:  int32(minor),
+			Minor:  deviceMinor(dev),
 		})
 	}
 
@@ -114,3 +180,29 @@ func ConvertDeviceInfo(devs []*pluginapi.Device) *[]*util.DeviceInfo {
 
 	return &res
 }
+
+// registeredMemory is the memory of a device in units of
+// config.GPUMemoryFactor MiB.
+func registeredMemory(dev *rm.Device) int {
+	return int(dev.TotalMemory/(1024*1024)) / int(config.GPUMemoryFactor)
+}
+
+// deviceMinor returns the minor number of the /dev/nvidiaN node of a device,
+// falling back to its index for devices without one, such as on Tegra or
+// WSL, or -1.
+func deviceMinor(dev *rm.Device) int32 {
+	for _, path := range dev.Paths {
+		n, ok := strings.CutPrefix(path, "/dev/nvidia")
+		if !ok {
+			continue
+		}
+		if minor, err := strconv.ParseInt(n, 10, 32); err == nil {
+			return int32(minor)
+		}
+	}
+	if index, err := strconv.ParseInt(dev.Index, 10, 32); err == nil {
+		return int32(index)
+	}
+	klog.Warningf("failed to get minor number for device id=%s, setting to -1", dev.ID)
+	return -1
+}
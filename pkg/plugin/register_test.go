@@ -0,0 +1,181 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/rm"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+func TestPatchNodeCapacity(t *testing.T) {
+	defer func(mem, priority string) {
+		util.ResourceMem, util.ResourcePriority = mem, priority
+	}(util.ResourceMem, util.ResourcePriority)
+	util.ResourceMem = "volcano.sh/vgpu-memory"
+	util.ResourcePriority = "volcano.sh/vgpu-priority"
+
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	})
+	devices := []*util.DeviceInfo{
+		{Id: "GPU-0", Devmem: 40960, Health: true},
+		{Id: "GPU-1", Devmem: 40960, Health: true},
+		{Id: "GPU-2", Devmem: 40960, Health: false},
+	}
+	capacity := map[string]int64{
+		util.ResourceMem:      memoryCapacity(devices),
+		util.ResourcePriority: priorityCapacity,
+	}
+
+	require.NoError(t, patchNodeCapacity(client, "node1", capacity))
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	mem := node.Status.Capacity[corev1.ResourceName(util.ResourceMem)]
+	require.Equal(t, int64(81920), mem.Value())
+	priority := node.Status.Capacity[corev1.ResourceName(util.ResourcePriority)]
+	require.Equal(t, int64(priorityCapacity), priority.Value())
+
+	// An unchanged capacity is not patched again.
+	client.ClearActions()
+	require.NoError(t, patchNodeCapacity(client, "node1", capacity))
+	for _, action := range client.Actions() {
+		require.NotEqual(t, "patch", action.GetVerb())
+	}
+
+	require.Error(t, patchNodeCapacity(client, "node2", capacity))
+}
+
+func TestNodeCapacity(t *testing.T) {
+	defer func(priority string) { util.ResourcePriority = priority }(util.ResourcePriority)
+
+	util.ResourcePriority = ""
+	require.Empty(t, nodeCapacity(nil, false))
+
+	util.ResourcePriority = "volcano.sh/vgpu-priority"
+	require.Equal(t, map[string]int64{util.ResourcePriority: priorityCapacity}, nodeCapacity(nil, false))
+}
+
+func TestConvertDeviceInfo(t *testing.T) {
+	defer func(factor, count uint, mode string) {
+		config.GPUMemoryFactor, config.DeviceSplitCount, config.Mode = factor, count, mode
+	}(config.GPUMemoryFactor, config.DeviceSplitCount, config.Mode)
+	config.GPUMemoryFactor = 1
+	config.DeviceSplitCount = 10
+	config.Mode = "hami-core"
+
+	testCases := []struct {
+		description string
+		devices     rm.Devices
+		expected    []*util.DeviceInfo
+	}{
+		{
+			description: "GPUs ordered by minor number",
+			devices: rm.Devices{
+				"GPU-1": {
+					Device:      pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy},
+					Paths:       []string{"/dev/nvidia0"},
+					Index:       "1",
+					TotalMemory: 40960 << 20,
+					Model:       "NVIDIA A100-SXM4-40GB",
+				},
+				"GPU-0": {
+					Device:      pluginapi.Device{ID: "GPU-0", Health: pluginapi.Unhealthy},
+					Paths:       []string{"/dev/nvidia1"},
+					Index:       "0",
+					TotalMemory: 40960 << 20,
+					Model:       "NVIDIA A100-SXM4-40GB",
+				},
+			},
+			expected: []*util.DeviceInfo{
+				{Id: "GPU-1", Count: 10, Devmem: 40960, Mode: "hami-core", Type: "NVIDIA-NVIDIA A100-SXM4-40GB", Health: true, Minor: 0},
+				{Id: "GPU-0", Count: 10, Devmem: 40960, Mode: "hami-core", Type: "NVIDIA-NVIDIA A100-SXM4-40GB", Health: false, Minor: 1},
+			},
+		},
+		{
+			description: "Tegra device without device node",
+			devices: rm.Devices{
+				"tegra": {
+					Device:      pluginapi.Device{ID: "tegra", Health: pluginapi.Healthy},
+					Index:       "0",
+					TotalMemory: 31000 << 20,
+					Model:       "Tegra",
+				},
+			},
+			expected: []*util.DeviceInfo{
+				{Id: "tegra", Count: 10, Devmem: 31000, Mode: "hami-core", Type: "NVIDIA-Tegra", Health: true, Minor: 0},
+			},
+		},
+		{
+			description: "device of unknown memory is not registered",
+			devices: rm.Devices{
+				"GPU-0": {
+					Device:      pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy},
+					Paths:       []string{"/dev/nvidia0"},
+					TotalMemory: 40960 << 20,
+					Model:       "NVIDIA A100-SXM4-40GB",
+				},
+				"GPU-1": {
+					Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy},
+					Paths:  []string{"/dev/nvidia1"},
+				},
+			},
+			expected: []*util.DeviceInfo{
+				{Id: "GPU-0", Count: 10, Devmem: 40960, Mode: "hami-core", Type: "NVIDIA-NVIDIA A100-SXM4-40GB", Health: true, Minor: 0},
+			},
+		},
+		{
+			description: "WSL device registered once per replica",
+			devices: rm.Devices{
+				"GPU-0::0": {
+					Device:      pluginapi.Device{ID: "GPU-0::0", Health: pluginapi.Healthy},
+					Paths:       []string{"/dev/dxg"},
+					Index:       "0",
+					TotalMemory: 8192 << 20,
+					Model:       "NVIDIA GeForce RTX 3070",
+					Replicas:    2,
+				},
+				"GPU-0::1": {
+					Device:      pluginapi.Device{ID: "GPU-0::1", Health: pluginapi.Healthy},
+					Paths:       []string{"/dev/dxg"},
+					Index:       "0",
+					TotalMemory: 8192 << 20,
+					Model:       "NVIDIA GeForce RTX 3070",
+					Replicas:    2,
+				},
+			},
+			expected: []*util.DeviceInfo{
+				{Id: "GPU-0", Count: 10, Devmem: 8192, Mode: "hami-core", Type: "NVIDIA-NVIDIA GeForce RTX 3070", Health: true, Minor: 0},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, *ConvertDeviceInfo(tc.devices))
+		})
+	}
+}
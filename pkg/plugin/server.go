@@ -26,10 +26,12 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
 
 	spec "volcano.sh/k8s-device-plugin/api/config/v1"
 	"volcano.sh/k8s-device-plugin/pkg/cdi"
@@ -45,6 +47,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"gopkg.in/yaml.v2"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
@@ -79,6 +82,7 @@ type nvidiaDevicePlugin struct {
 	pluginapi.UnimplementedDevicePluginServer
 	ctx                  context.Context
 	rm                   rm.ResourceManager
+	nvml                 nvml.Interface
 	config               *spec.Config
 	deviceListStrategies spec.DeviceListStrategies
 
@@ -88,9 +92,23 @@ type nvidiaDevicePlugin struct {
 	socket string
 	server *grpc.Server
 	health chan *rm.Device
-	stop   chan interface{}
+	// recovered receives the devices the resource manager found healthy
+	// again.
+	recovered chan *rm.Device
+	stop      chan interface{}
+	// mpsUnhealthy receives the devices to mark unhealthy while the MPS
+	// daemon serving them is down, and healthy those to mark healthy again
+	// once it recovered.
+	mpsUnhealthy chan *rm.Device
+	healthy      chan *rm.Device
+	// supervised is closed once the MPS daemon is no longer supervised.
+	supervised chan struct{}
+	// registering is closed once the devices are no longer registered in the
+	// node.
+	registering chan struct{}
 
 	imexChannels imex.Channels
+	imexPool     *imex.Pool
 
 	mps mpsOptions
 
@@ -100,6 +118,13 @@ type nvidiaDevicePlugin struct {
 	// since apiDevices runs again on every health event. Only ListAndWatch
 	// reaches it, so no synchronisation is needed.
 	entryLimitWarned bool
+	// failed holds the IDs of the devices the resource manager reported
+	// unhealthy, which stay unhealthy when the MPS daemon recovers, and
+	// mpsDown those of the devices whose MPS daemon is down, which stay
+	// unhealthy when the resource manager finds them healthy again. Only
+	// ListAndWatch reaches them.
+	failed  map[string]bool
+	mpsDown map[string]bool
 }
 
 // devicePluginForResource creates a device plugin for the specified resource.
@@ -112,6 +137,7 @@ func (o *options) devicePluginForResource(ctx context.Context, resourceManager r
 	plugin := nvidiaDevicePlugin{
 		ctx:                  ctx,
 		rm:                   resourceManager,
+		nvml:                 config.Nvml(),
 		config:               o.config,
 		deviceListStrategies: o.deviceListStrategies,
 
@@ -119,6 +145,7 @@ func (o *options) devicePluginForResource(ctx context.Context, resourceManager r
 		cdiAnnotationPrefix: *o.config.Flags.Plugin.CDIAnnotationPrefix,
 
 		imexChannels: o.imexChannels,
+		imexPool:     o.imexPool,
 
 		mps: mpsOptions,
 
@@ -140,8 +167,13 @@ func getPluginSocketPath(resource spec.ResourceName) string {
 }
 
 func (plugin *nvidiaDevicePlugin) initialize() {
-	plugin.server = grpc.NewServer([]grpc.ServerOption{}...)
+	// Stop waits for the handlers, as ListAndWatch reads the channels
+	// cleanup resets.
+	plugin.server = grpc.NewServer(grpc.WaitForHandlers(true))
 	plugin.health = make(chan *rm.Device)
+	plugin.recovered = make(chan *rm.Device)
+	plugin.mpsUnhealthy = make(chan *rm.Device)
+	plugin.healthy = make(chan *rm.Device)
 	plugin.stop = make(chan interface{})
 }
 
@@ -149,6 +181,9 @@ func (plugin *nvidiaDevicePlugin) cleanup() {
 	close(plugin.stop)
 	plugin.server = nil
 	plugin.health = nil
+	plugin.recovered = nil
+	plugin.mpsUnhealthy = nil
+	plugin.healthy = nil
 	plugin.stop = nil
 }
 
@@ -162,12 +197,12 @@ func (plugin *nvidiaDevicePlugin) Devices() rm.Devices {
 func (plugin *nvidiaDevicePlugin) Start(kubeletSocket string) error {
 	plugin.initialize()
 
-	deviceNumbers, err := util.GetDeviceNums()
-	if err != nil {
+	if err := plugin.mps.startDaemon(); err != nil {
+		plugin.cleanup()
 		return err
 	}
-
 	if err := plugin.mps.waitForDaemon(); err != nil {
+		plugin.cleanup()
 		return fmt.Errorf("error waiting for MPS daemon: %w", err)
 	}
 
@@ -178,29 +213,40 @@ func (plugin *nvidiaDevicePlugin) Start(kubeletSocket string) error {
 	}
 	klog.Infof("Starting to serve '%s' on %s", plugin.rm.Resource(), plugin.socket)
 
-	err = plugin.Register(kubeletSocket)
+	err := plugin.Register(kubeletSocket)
 	if err != nil {
 		klog.Errorf("Could not register device plugin: %s", err)
 		return errors.Join(err, plugin.Stop())
 	}
 	klog.Infof("Registered device plugin for '%s' with Kubelet", plugin.rm.Resource())
 
-	go func() {
-		// TODO: add MPS health check
-		err := plugin.rm.CheckHealth(plugin.stop, plugin.health)
+	go func(stop <-chan interface{}, unhealthy, healthy chan<- *rm.Device) {
+		err := plugin.rm.CheckHealth(stop, unhealthy, healthy)
 		if err != nil {
 			klog.Errorf("Failed to start health check: %v; continuing with health checks disabled", err)
 		}
-	}()
+	}(plugin.stop, plugin.health, plugin.recovered)
+	if plugin.mps.enabled {
+		supervised := make(chan struct{})
+		plugin.supervised = supervised
+		go func(stop <-chan interface{}, unhealthy, healthy chan<- *rm.Device) {
+			defer close(supervised)
+			plugin.mps.supervise(stop, mpsHealthCheckInterval, plugin.rm.Devices(), unhealthy, healthy)
+		}(plugin.stop, plugin.mpsUnhealthy, plugin.healthy)
+	}
 	if plugin.rm.Resource() == spec.ResourceName(util.ResourceName) {
 		if config.Mode == "mig" {
+			deviceNumbers, err := util.GetDeviceNums(plugin.nvml)
+			if err != nil {
+				return errors.Join(err, plugin.Stop())
+			}
 			cmd := exec.Command("nvidia-mig-parted", "export")
 			var stdout, stderr bytes.Buffer
 			cmd.Stdout = &stdout
 			cmd.Stderr = &stderr
-			err := cmd.Run()
+			err = cmd.Run()
 			if err != nil {
-				klog.Fatalf("nvidia-mig-parted failed with %s\n", err)
+				return errors.Join(fmt.Errorf("nvidia-mig-parted failed: %w: %s", err, stderr.String()), plugin.Stop())
 			}
 			outStr := stdout.Bytes()
 			yaml.Unmarshal(outStr, &plugin.migCurrent)
@@ -213,7 +259,12 @@ func (plugin *nvidiaDevicePlugin) Start(kubeletSocket string) error {
 			klog.Infoln("Mig export", plugin.migCurrent)
 		}
 
-		go plugin.WatchAndRegister()
+		registering := make(chan struct{})
+		plugin.registering = registering
+		go func(stop <-chan interface{}) {
+			defer close(registering)
+			plugin.WatchAndRegister(stop)
+		}(plugin.stop)
 	}
 	return nil
 }
@@ -229,6 +280,15 @@ func (plugin *nvidiaDevicePlugin) Stop() error {
 		return err
 	}
 	plugin.cleanup()
+	// The supervisor may be restarting the daemon.
+	if plugin.supervised != nil {
+		<-plugin.supervised
+		plugin.supervised = nil
+	}
+	if plugin.registering != nil {
+		<-plugin.registering
+		plugin.registering = nil
+	}
 	return nil
 }
 
@@ -331,13 +391,51 @@ func (plugin *nvidiaDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.D
 		select {
 		case <-plugin.stop:
 			return nil
+		case <-s.Context().Done():
+			// The kubelet closed the stream, or the server is stopping.
+			return nil
 		case d := <-plugin.health:
-			// FIXME: there is no way to recover from the Unhealthy state.
 			d.Health = pluginapi.Unhealthy
+			if plugin.failed == nil {
+				plugin.failed = make(map[string]bool)
+			}
+			plugin.failed[d.ID] = true
 			klog.Infof("'%s' device marked unhealthy: %s", plugin.rm.Resource(), d.ID)
 			if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
 				return nil
 			}
+		case d := <-plugin.recovered:
+			delete(plugin.failed, d.ID)
+			if plugin.mpsDown[d.ID] {
+				klog.Infof("'%s' device kept unhealthy while the MPS daemon is down: %s", plugin.rm.Resource(), d.ID)
+				continue
+			}
+			d.Health = pluginapi.Healthy
+			klog.Infof("'%s' device marked healthy: %s", plugin.rm.Resource(), d.ID)
+			if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
+				return nil
+			}
+		case d := <-plugin.mpsUnhealthy:
+			d.Health = pluginapi.Unhealthy
+			if plugin.mpsDown == nil {
+				plugin.mpsDown = make(map[string]bool)
+			}
+			plugin.mpsDown[d.ID] = true
+			klog.Infof("'%s' device marked unhealthy while the MPS daemon is down: %s", plugin.rm.Resource(), d.ID)
+			if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
+				return nil
+			}
+		case d := <-plugin.healthy:
+			delete(plugin.mpsDown, d.ID)
+			if plugin.failed[d.ID] {
+				klog.Infof("'%s' device kept unhealthy after the MPS daemon recovered: %s", plugin.rm.Resource(), d.ID)
+				continue
+			}
+			d.Health = pluginapi.Healthy
+			klog.Infof("'%s' device marked healthy: %s", plugin.rm.Resource(), d.ID)
+			if err := s.Send(&pluginapi.ListAndWatchResponse{Devices: plugin.apiDevices()}); err != nil {
+				return nil
+			}
 		}
 	}
 }
@@ -386,6 +484,12 @@ func (plugin *nvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.
 	}
 	klog.V(3).InfoS("Current pending pod.", "UID", current.UID, "pod name", current.Name)
 
+	imexChannels, err := plugin.imexChannelsFor(current)
+	if err != nil {
+		util.PodAllocationFailed(nodeName, current)
+		return nil, fmt.Errorf("failed to allocate IMEX channel: %w", err)
+	}
+
 	for idx, req := range reqs.ContainerRequests {
 		if strings.Contains(req.DevicesIds[0], "MIG") {
 			if plugin.config.Sharing.TimeSlicing.FailRequestsGreaterThanOne && rm.AnnotatedIDs(req.DevicesIds).AnyHasAnnotations() {
@@ -402,7 +506,7 @@ func (plugin *nvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.
 				}
 			}
 
-			response, err := plugin.getAllocateResponse(req.DevicesIds)
+			response, err := plugin.getAllocateResponse(req.DevicesIds, imexChannels)
 			if err != nil {
 				util.PodAllocationFailed(nodeName, current)
 				return nil, fmt.Errorf("failed to get allocate response: %v", err)
@@ -424,8 +528,37 @@ func (plugin *nvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.
 				return &pluginapi.AllocateResponse{}, errors.New("device number not matched")
 			}
 
-			response, err := plugin.getAllocateResponse(plugin.GetContainerDeviceStrArray(devreq))
+			mode, err := injectionModeFor(current, &currentCtr)
+			if err != nil {
+				util.PodAllocationFailed(nodeName, current)
+				return &pluginapi.AllocateResponse{}, err
+			}
+			corePolicy, err := coreLimitPolicyFor(current, currentCtr.Name)
 			if err != nil {
+				util.PodAllocationFailed(nodeName, current)
+				return &pluginapi.AllocateResponse{}, err
+			}
+			priority, err := taskPriorityFor(current, &currentCtr)
+			if err != nil {
+				util.PodAllocationFailed(nodeName, current)
+				return &pluginapi.AllocateResponse{}, err
+			}
+			if plugin.mps.managed {
+				if err := checkMPSDevices(devreq); err != nil {
+					util.PodAllocationFailed(nodeName, current)
+					return &pluginapi.AllocateResponse{}, err
+				}
+			}
+
+			deviceIDs, err := plugin.GetContainerDeviceStrArray(devreq)
+			if err != nil {
+				klog.Errorln("prepare devices failed", err.Error())
+				util.PodAllocationFailed(nodeName, current)
+				return &pluginapi.AllocateResponse{}, err
+			}
+			response, err := plugin.getAllocateResponse(deviceIDs, imexChannels)
+			if err != nil {
+				util.PodAllocationFailed(nodeName, current)
 				return nil, fmt.Errorf("failed to get allocate response: %v", err)
 			}
 			err = util.EraseNextDeviceTypeFromAnnotation(util.NvidiaGPUDevice, *current)
@@ -435,54 +568,70 @@ func (plugin *nvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.
 				return &pluginapi.AllocateResponse{}, err
 			}
 
-			if config.Mode != "mig" {
-				for i, dev := range devreq {
-					limitKey := fmt.Sprintf("CUDA_DEVICE_MEMORY_LIMIT_%v", i)
-					response.Envs[limitKey] = fmt.Sprintf("%vm", dev.Usedmem*int32(config.GPUMemoryFactor))
+			if config.Mode != "mig" && mode != injectionNone && plugin.mps.managed {
+				// The container is an MPS client, the pipe and shm
+				// directories of which getAllocateResponse mounted.
+				for k, v := range mpsLimitEnvs(devreq) {
+					response.Envs[k] = v
 				}
-				response.Envs["CUDA_DEVICE_SM_LIMIT"] = fmt.Sprint(devreq[0].Usedcores)
-				response.Envs["CUDA_DEVICE_MEMORY_SHARED_CACHE"] = fmt.Sprintf("/tmp/vgpu/%v.cache", uuid.New().String())
+			} else if config.Mode != "mig" && mode != injectionNone {
+				envs := limitEnvs(devreq)
+				envs["CUDA_DEVICE_MEMORY_SHARED_CACHE"] = fmt.Sprintf("%s/%v.cache", *plugin.config.Flags.Plugin.VGPUCachePath, uuid.New().String())
 
 				if config.DeviceCoresScaling > 1 {
-					response.Envs["CUDA_OVERSUBSCRIBE"] = "true"
+					envs["CUDA_OVERSUBSCRIBE"] = "true"
+				}
+				if corePolicy != "" {
+					envs[util.CoreLimitSwitch] = corePolicy
 				}
-				if config.DisableCoreLimit {
-					response.Envs[util.CoreLimitSwitch] = "disable"
+				if priority != "" {
+					envs[util.TaskPriority] = priority
 				}
 
-				hostHookPath := os.Getenv("HOOK_PATH")
-				cacheFileHostDirectory := fmt.Sprintf("%s/vgpu/containers/%s_%s", hostHookPath, current.UID, currentCtr.Name)
+				hostHookPath := *plugin.config.Flags.Plugin.HookPath
+				lockPath := *plugin.config.Flags.Plugin.VGPULockPath
+				cacheName := fmt.Sprintf("%s_%s", current.UID, currentCtr.Name)
+				cacheFileHostDirectory := filepath.Join(containerCacheRoot(hostHookPath), cacheName)
 				os.RemoveAll(cacheFileHostDirectory)
 
 				os.MkdirAll(cacheFileHostDirectory, 0777)
 				os.Chmod(cacheFileHostDirectory, 0777)
-				os.MkdirAll("/tmp/vgpulock", 0777)
-				os.Chmod("/tmp/vgpulock", 0777)
+				os.MkdirAll(lockPath, 0777)
+				os.Chmod(lockPath, 0777)
 
-				response.Mounts = append(response.Mounts,
-					&pluginapi.Mount{ContainerPath: "/usr/local/vgpu/libvgpu.so",
-						HostPath: hostHookPath + "/libvgpu.so",
+				mounts := []*pluginapi.Mount{
+					{ContainerPath: *plugin.config.Flags.Plugin.LibvgpuPath,
+						HostPath: filepath.Join(hostHookPath, libvgpuName),
 						ReadOnly: true},
-					&pluginapi.Mount{ContainerPath: "/tmp/vgpu",
+					{ContainerPath: *plugin.config.Flags.Plugin.VGPUCachePath,
 						HostPath: cacheFileHostDirectory,
 						ReadOnly: false},
-					&pluginapi.Mount{ContainerPath: "/tmp/vgpulock",
-						HostPath: "/tmp/vgpulock",
+					{ContainerPath: lockPath,
+						HostPath: lockPath,
 						ReadOnly: false},
-				)
-				found := false
-				for _, val := range currentCtr.Env {
-					if strings.Compare(val.Name, "CUDA_DISABLE_CONTROL") == 0 {
-						found = true
-						break
-					}
 				}
-				if !found {
-					response.Mounts = append(response.Mounts, &pluginapi.Mount{ContainerPath: "/etc/ld.so.preload",
-						HostPath: hostHookPath + "/ld.so.preload",
+				if mode == injectionFull {
+					mounts = append(mounts, &pluginapi.Mount{ContainerPath: "/etc/ld.so.preload",
+						HostPath: filepath.Join(hostHookPath, ldPreloadName),
 						ReadOnly: true},
 					)
 				}
+
+				// Runtimes applying CDI devices may not apply the mounts of
+				// the response, so they get a device of their own. With a
+				// non-CDI strategy as well the GPU may reach the container
+				// without CDI, so the response keeps them.
+				if plugin.deviceListStrategies.AllCDIEnabled() {
+					if err := plugin.updateResponseForVGPUCDI(response, cacheName, envs, mounts); err != nil {
+						util.PodAllocationFailed(nodeName, current)
+						return nil, fmt.Errorf("failed to get allocate response for vGPU CDI: %v", err)
+					}
+				} else {
+					for k, v := range envs {
+						response.Envs[k] = v
+					}
+					response.Mounts = append(response.Mounts, mounts...)
+				}
 			}
 			responses.ContainerResponses = append(responses.ContainerResponses, response)
 		}
@@ -492,7 +641,7 @@ func (plugin *nvidiaDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.
 	return &responses, nil
 }
 
-func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*pluginapi.ContainerAllocateResponse, error) {
+func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string, imexChannels imex.Channels) (*pluginapi.ContainerAllocateResponse, error) {
 	deviceIDs := plugin.uniqueDeviceIDsFromAnnotatedDeviceIDs(requestIds)
 
 	// Create an empty response that will be updated as required below.
@@ -501,7 +650,7 @@ func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 	}
 	if plugin.deviceListStrategies.AnyCDIEnabled() {
 		responseID := uuid.New().String()
-		if err := plugin.updateResponseForCDI(response, responseID, deviceIDs...); err != nil {
+		if err := plugin.updateResponseForCDI(response, responseID, imexChannels, deviceIDs...); err != nil {
 			return nil, fmt.Errorf("failed to get allocate response for CDI: %v", err)
 		}
 	}
@@ -527,13 +676,13 @@ func (plugin *nvidiaDevicePlugin) getAllocateResponse(requestIds []string) (*plu
 
 	if plugin.deviceListStrategies.Includes(spec.DeviceListStrategyEnvVar) {
 		plugin.updateResponseForDeviceListEnvVar(response, deviceIDs...)
-		plugin.updateResponseForImexChannelsEnvVar(response)
+		plugin.updateResponseForImexChannelsEnvVar(response, imexChannels)
 	}
 	if plugin.deviceListStrategies.Includes(spec.DeviceListStrategyVolumeMounts) {
-		plugin.updateResponseForDeviceMounts(response, deviceIDs...)
+		plugin.updateResponseForDeviceMounts(response, imexChannels, deviceIDs...)
 	}
 	if plugin.config.Flags.Plugin.PassDeviceSpecs != nil && *plugin.config.Flags.Plugin.PassDeviceSpecs {
-		response.Devices = append(response.Devices, plugin.apiDeviceSpecs(*plugin.config.Flags.NvidiaDevRoot, requestIds)...)
+		response.Devices = append(response.Devices, plugin.apiDeviceSpecs(*plugin.config.Flags.NvidiaDevRoot, requestIds, imexChannels)...)
 	}
 	return response, nil
 }
@@ -547,12 +696,12 @@ func (plugin nvidiaDevicePlugin) updateResponseForMPS(response *pluginapi.Contai
 
 // updateResponseForCDI updates the specified response for the given device IDs.
 // This response contains the annotations required to trigger CDI injection in the container engine or nvidia-container-runtime.
-func (plugin *nvidiaDevicePlugin) updateResponseForCDI(response *pluginapi.ContainerAllocateResponse, responseID string, deviceIDs ...string) error {
+func (plugin *nvidiaDevicePlugin) updateResponseForCDI(response *pluginapi.ContainerAllocateResponse, responseID string, imexChannels imex.Channels, deviceIDs ...string) error {
 	var devices []string
 	for _, id := range deviceIDs {
 		devices = append(devices, plugin.cdiHandler.QualifiedName("gpu", id))
 	}
-	for _, channel := range plugin.imexChannels {
+	for _, channel := range imexChannels {
 		devices = append(devices, plugin.cdiHandler.QualifiedName("imex-channel", channel.ID))
 	}
 
@@ -562,12 +711,49 @@ func (plugin *nvidiaDevicePlugin) updateResponseForCDI(response *pluginapi.Conta
 		return nil
 	}
 
+	return plugin.addCDIDevices(response, responseID, devices...)
+}
+
+// updateResponseForVGPUCDI injects envs and mounts through a CDI device
+// created for the container, name.
+func (plugin *nvidiaDevicePlugin) updateResponseForVGPUCDI(response *pluginapi.ContainerAllocateResponse, name string, envs map[string]string, mounts []*pluginapi.Mount) error {
+	var edits cdispecs.ContainerEdits
+	for k, v := range envs {
+		edits.Env = append(edits.Env, k+"="+v)
+	}
+	sort.Strings(edits.Env)
+	for _, m := range mounts {
+		options := []string{"nosuid", "nodev", "bind"}
+		if m.ReadOnly {
+			options = append([]string{"ro"}, options...)
+		}
+		edits.Mounts = append(edits.Mounts, &cdispecs.Mount{
+			HostPath:      m.HostPath,
+			ContainerPath: m.ContainerPath,
+			Options:       options,
+		})
+	}
+	device, err := plugin.cdiHandler.CreateVGPUSpecFile(name, edits)
+	if err != nil {
+		return err
+	}
+	return plugin.addCDIDevices(response, uuid.New().String(), device)
+}
+
+// addCDIDevices requests the CDI devices through the configured CDI device
+// list strategies.
+func (plugin *nvidiaDevicePlugin) addCDIDevices(response *pluginapi.ContainerAllocateResponse, responseID string, devices ...string) error {
 	if plugin.deviceListStrategies.Includes(spec.DeviceListStrategyCDIAnnotations) {
 		annotations, err := plugin.getCDIDeviceAnnotations(responseID, devices...)
 		if err != nil {
 			return err
 		}
-		response.Annotations = annotations
+		if response.Annotations == nil {
+			response.Annotations = make(map[string]string)
+		}
+		for k, v := range annotations {
+			response.Annotations[k] = v
+		}
 	}
 	if plugin.deviceListStrategies.Includes(spec.DeviceListStrategyCDICRI) {
 		for _, device := range devices {
@@ -646,8 +832,18 @@ func (plugin *nvidiaDevicePlugin) uniqueDeviceIDsFromAnnotatedDeviceIDs(ids []st
 	return uniqueIDs
 }
 
+// deviceHealth returns the health reported for a device. A device whose memory
+// could not be read from the driver is unhealthy, as its vGPU resources can
+// not be accounted for.
+func deviceHealth(dev *rm.Device) string {
+	if dev.TotalMemory == 0 {
+		return pluginapi.Unhealthy
+	}
+	return dev.Health
+}
+
 func (plugin *nvidiaDevicePlugin) apiDevices() []*pluginapi.Device {
-	devs := plugin.rm.Devices().GetPluginDevices()
+	devs := plugin.rm.Devices()
 	/*if strings.Compare(plugin.migStrategy, "mixed") == 0 {
 		return devs
 	}*/
@@ -655,24 +851,13 @@ func (plugin *nvidiaDevicePlugin) apiDevices() []*pluginapi.Device {
 
 	if plugin.rm.Resource() == spec.ResourceName(util.ResourceMem) {
 		for _, dev := range devs {
-			ndev, ret := config.Nvml().DeviceGetHandleByUUID(dev.ID)
-			if ret != nvml.SUCCESS {
-				fmt.Println("nvml new device by uuid error id=", dev.ID)
-				panic(ret)
-			}
-
-			memory, ret := config.Nvml().DeviceGetMemoryInfo(ndev)
-			if ret != nvml.SUCCESS {
-				fmt.Println("failed to get memory info for device id=", dev.ID)
-				panic(ret)
-			}
-			registeredmem := int(memory.Total/(1024*1024)) / int(config.GPUMemoryFactor)
+			registeredmem := registeredMemory(dev)
 			i := 0
 			klog.Infoln("memory=", registeredmem, "id=", dev.ID)
 			for i < registeredmem {
 				res = append(res, &pluginapi.Device{
 					ID:       fmt.Sprintf("%v-memory-%v", dev.ID, i),
-					Health:   dev.Health,
+					Health:   deviceHealth(dev),
 					Topology: nil,
 				})
 				i++
@@ -693,13 +878,24 @@ func (plugin *nvidiaDevicePlugin) apiDevices() []*pluginapi.Device {
 			for i < coresNum {
 				res = append(res, &pluginapi.Device{
 					ID:       fmt.Sprintf("%v-core-%v", dev.ID, i),
-					Health:   dev.Health,
+					Health:   deviceHealth(dev),
 					Topology: nil,
 				})
 				i++
 			}
 		}
 		return res
+	} else if plugin.rm.Resource() == spec.ResourceName(util.ResourceMemPercentage) {
+		for _, dev := range devs {
+			for i := 0; i < memoryPercentageNum(); i++ {
+				res = append(res, &pluginapi.Device{
+					ID:       fmt.Sprintf("%v-mempercentage-%v", dev.ID, i),
+					Health:   deviceHealth(dev),
+					Topology: nil,
+				})
+			}
+		}
+		return res
 	}
 
 	for _, dev := range devs {
@@ -707,7 +903,7 @@ func (plugin *nvidiaDevicePlugin) apiDevices() []*pluginapi.Device {
 			id := fmt.Sprintf("%v-%v", dev.ID, i)
 			res = append(res, &pluginapi.Device{
 				ID:       id,
-				Health:   dev.Health,
+				Health:   deviceHealth(dev),
 				Topology: nil,
 			})
 		}
@@ -720,10 +916,30 @@ func (plugin *nvidiaDevicePlugin) updateResponseForDeviceListEnvVar(response *pl
 	response.Envs[deviceListEnvVar] = strings.Join(deviceIDs, ",")
 }
 
+// imexChannelsFor returns the IMEX channels of the containers of a pod: the
+// channel of the pool allocated to it if it asks for one, the configured
+// channels otherwise.
+func (plugin *nvidiaDevicePlugin) imexChannelsFor(pod *corev1.Pod) (imex.Channels, error) {
+	if plugin.imexPool == nil {
+		if _, ok := pod.Annotations[util.ImexChannelAnnotation]; ok {
+			return nil, fmt.Errorf("pod %s/%s asks for an IMEX channel, but no channel pool is configured", pod.Namespace, pod.Name)
+		}
+		return plugin.imexChannels, nil
+	}
+	channel, err := plugin.imexPool.Allocate(pod)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return plugin.imexChannels, nil
+	}
+	return imex.Channels{channel}, nil
+}
+
 // updateResponseForImexChannelsEnvVar sets the environment variable for the requested IMEX channels.
-func (plugin *nvidiaDevicePlugin) updateResponseForImexChannelsEnvVar(response *pluginapi.ContainerAllocateResponse) {
+func (plugin *nvidiaDevicePlugin) updateResponseForImexChannelsEnvVar(response *pluginapi.ContainerAllocateResponse, imexChannels imex.Channels) {
 	var channelIDs []string
-	for _, channel := range plugin.imexChannels {
+	for _, channel := range imexChannels {
 		channelIDs = append(channelIDs, channel.ID)
 	}
 	if len(channelIDs) > 0 {
@@ -732,7 +948,7 @@ func (plugin *nvidiaDevicePlugin) updateResponseForImexChannelsEnvVar(response *
 }
 
 // updateResponseForDeviceMounts sets the mounts required to request devices if volume mounts are used.
-func (plugin *nvidiaDevicePlugin) updateResponseForDeviceMounts(response *pluginapi.ContainerAllocateResponse, deviceIDs ...string) {
+func (plugin *nvidiaDevicePlugin) updateResponseForDeviceMounts(response *pluginapi.ContainerAllocateResponse, imexChannels imex.Channels, deviceIDs ...string) {
 	plugin.updateResponseForDeviceListEnvVar(response, deviceListAsVolumeMountsContainerPathRoot)
 
 	for _, id := range deviceIDs {
@@ -742,7 +958,7 @@ func (plugin *nvidiaDevicePlugin) updateResponseForDeviceMounts(response *plugin
 		}
 		response.Mounts = append(response.Mounts, mount)
 	}
-	for _, channel := range plugin.imexChannels {
+	for _, channel := range imexChannels {
 		mount := &pluginapi.Mount{
 			HostPath:      deviceListAsVolumeMountsHostPath,
 			ContainerPath: filepath.Join(deviceListAsVolumeMountsContainerPathRoot, "imex", channel.ID),
@@ -751,7 +967,7 @@ func (plugin *nvidiaDevicePlugin) updateResponseForDeviceMounts(response *plugin
 	}
 }
 
-func (plugin *nvidiaDevicePlugin) apiDeviceSpecs(devRoot string, ids []string) []*pluginapi.DeviceSpec {
+func (plugin *nvidiaDevicePlugin) apiDeviceSpecs(devRoot string, ids []string, imexChannels imex.Channels) []*pluginapi.DeviceSpec {
 	optional := map[string]bool{
 		"/dev/nvidiactl":        true,
 		"/dev/nvidia-uvm":       true,
@@ -776,7 +992,7 @@ func (plugin *nvidiaDevicePlugin) apiDeviceSpecs(devRoot string, ids []string) [
 		specs = append(specs, spec)
 	}
 
-	for _, channel := range plugin.imexChannels {
+	for _, channel := range imexChannels {
 		spec := &pluginapi.DeviceSpec{
 			ContainerPath: channel.Path,
 			// TODO: The HostPath property for a channel is not the correct value to use here.
@@ -794,20 +1010,29 @@ func (plugin *nvidiaDevicePlugin) apiDeviceSpecs(devRoot string, ids []string) [
 	return specs
 }
 
-func (plugin *nvidiaDevicePlugin) WatchAndRegister() {
+// WatchAndRegister registers the devices in the node annotation and capacity
+// every 30 seconds, until stop is closed.
+func (plugin *nvidiaDevicePlugin) WatchAndRegister(stop <-chan interface{}) {
 	klog.Infof("into WatchAndRegister")
 	for {
+		interval := 30 * time.Second
 		if len(config.Mode) == 0 {
 			klog.V(5).Info("register skipped, waiting for device config to be loaded")
-			time.Sleep(time.Second * 2)
-			continue
-		}
-		err := RegisterInAnnotation(plugin.rm.Devices().GetPluginDevices())
-		if err != nil {
-			klog.Errorf("register error, %v", err)
-			time.Sleep(time.Second * 5)
+			interval = 2 * time.Second
 		} else {
-			time.Sleep(time.Second * 30)
+			err := RegisterInAnnotation(plugin.rm.Devices())
+			if err == nil {
+				err = RegisterCapacity(plugin.rm.Devices(), plugin.config.Flags.Plugin.MemoryInNodeStatus())
+			}
+			if err != nil {
+				klog.Errorf("register error, %v", err)
+				interval = 5 * time.Second
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
 		}
 	}
 }
@@ -832,26 +1057,51 @@ func containsDevice(target int, devices []int32) bool {
 	return false
 }
 
-func (plugin *nvidiaDevicePlugin) ApplyMigTemplate() {
+func (plugin *nvidiaDevicePlugin) ApplyMigTemplate() error {
 	data, err := yaml.Marshal(plugin.migCurrent)
 	if err != nil {
-		klog.Error("marshal failed", err.Error())
+		return fmt.Errorf("failed to marshal mig config: %v", err)
 	}
 	klog.Infoln("Applying data=", string(data))
-	os.WriteFile("/tmp/migconfig.yaml", data, os.ModePerm)
+	if err := os.WriteFile("/tmp/migconfig.yaml", data, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write mig config: %v", err)
+	}
 	cmd := exec.Command("nvidia-mig-parted", "apply", "-f", "/tmp/migconfig.yaml")
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	err = cmd.Run()
 	if err != nil {
-		klog.Fatalf("nvidia-mig-parted failed with %s\n", err)
+		return fmt.Errorf("nvidia-mig-parted failed with %v: %s", err, stderr.String())
 	}
 	outStr := stdout.String()
 	klog.Infoln("Mig apply", outStr)
+	return nil
 }
 
-func (plugin *nvidiaDevicePlugin) GetContainerDeviceStrArray(c util.ContainerDevices) []string {
+// regenerateCDISpec regenerates the CDI spec after the MIG devices changed.
+// The MIG devices and their nvidia-cap nodes may take a while to show up,
+// so it is retried until the spec validates.
+func (plugin *nvidiaDevicePlugin) regenerateCDISpec() error {
+	const (
+		maxTryTimes      = 5
+		waitTimeInterval = 5 * time.Second
+	)
+	var err error
+	for i := 0; i < maxTryTimes; i++ {
+		if i > 0 {
+			time.Sleep(waitTimeInterval)
+		}
+		if err = plugin.cdiHandler.CreateSpecFile(); err == nil {
+			klog.Infof("Regenerated CDI spec")
+			return nil
+		}
+		klog.Warningf("Failed to regenerate CDI spec, try %d/%d: %v", i+1, maxTryTimes, err)
+	}
+	return fmt.Errorf("failed to regenerate CDI spec after %d tries: %v", maxTryTimes, err)
+}
+
+func (plugin *nvidiaDevicePlugin) GetContainerDeviceStrArray(c util.ContainerDevices) ([]string, error) {
 	tmp := []string{}
 	needsreset := false
 	position := 0
@@ -859,46 +1109,31 @@ func (plugin *nvidiaDevicePlugin) GetContainerDeviceStrArray(c util.ContainerDev
 		if !strings.Contains(val.UUID, "[") {
 			tmp = append(tmp, val.UUID)
 		} else {
-			devtype, devindex := util.GetIndexAndTypeFromUUID(val.UUID)
+			devtype, devindex, err := util.GetIndexAndTypeFromUUID(plugin.nvml, val.UUID)
+			if err != nil {
+				return nil, err
+			}
 			position, needsreset = plugin.GenerateMigTemplate(devtype, devindex, val)
 			if needsreset {
-				plugin.ApplyMigTemplate()
-				if plugin.deviceListStrategies.Includes(spec.DeviceListStrategyVolumeMounts) ||
-					plugin.deviceListStrategies.Includes(spec.DeviceListStrategyCDIAnnotations) ||
-					plugin.deviceListStrategies.Includes(spec.DeviceListStrategyCDICRI) {
-					klog.V(3).Infoln("generate CDI spec file")
-					const (
-						maxTryTimes      = 5
-						waitTimeInterval = 5 * time.Second
-						specFilePath     = "/var/run/cdi/k8s.device-plugin.nvidia.com-gpu.json"
-						kind             = "k8s.device-plugin.nvidia.com/gpu"
-					)
-					for i := 0; i < maxTryTimes; i++ {
-						if err := util.CreateSpecFile(specFilePath); err != nil {
-							klog.Warningf("failed to create CDI spec file: %v", err)
-						} else {
-							klog.Infof("createSpecFile ok. file path %s", specFilePath)
-						}
-						if err := util.CheckCDISpecFile(specFilePath, kind); err != nil {
-							klog.Warningf("check CDI spec file failed. %v", err)
-							if i == maxTryTimes-1 {
-								klog.Fatalf("exceed the max trytime %d", maxTryTimes)
-							} else {
-								time.Sleep(waitTimeInterval)
-								klog.Warningf("try to create CDI spec file again. try times: %d", i)
-								continue
-							}
-						}
-						klog.Infof("check CDI spec file ok")
-						break
+				if err := plugin.ApplyMigTemplate(); err != nil {
+					return nil, err
+				}
+				if plugin.deviceListStrategies.AnyCDIEnabled() ||
+					plugin.deviceListStrategies.Includes(spec.DeviceListStrategyVolumeMounts) {
+					if err := plugin.regenerateCDISpec(); err != nil {
+						return nil, err
 					}
 				}
 			}
-			tmp = append(tmp, util.GetMigUUIDFromIndex(val.UUID, position))
+			migUUID, err := util.GetMigUUIDFromIndex(plugin.nvml, val.UUID, position)
+			if err != nil {
+				return nil, err
+			}
+			tmp = append(tmp, migUUID)
 		}
 	}
 	klog.V(3).Infoln("mig current=", plugin.migCurrent, ":", needsreset, "position=", position, "uuid lists", tmp)
-	return tmp
+	return tmp, nil
 }
 
 func (plugin *nvidiaDevicePlugin) GenerateMigTemplate(devtype string, devindex int, val util.ContainerDevice) (int, bool) {
@@ -21,58 +21,71 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	cdispecs "tags.cncf.io/container-device-interface/specs-go"
 
 	v1 "volcano.sh/k8s-device-plugin/api/config/v1"
 	"volcano.sh/k8s-device-plugin/pkg/cdi"
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	ct "volcano.sh/k8s-device-plugin/pkg/config/testing"
 	"volcano.sh/k8s-device-plugin/pkg/imex"
 	"volcano.sh/k8s-device-plugin/pkg/rm"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+	"volcano.sh/k8s-device-plugin/pkg/util/client"
 )
 
 func TestAllocate(t *testing.T) {
+	defer func(name string) { util.ResourceName = name }(util.ResourceName)
+	util.ResourceName = "volcano.sh/vgpu-number"
+	t.Setenv("NODE_NAME", "node1")
+	client.SetClient(fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	}))
+
 	testCases := []struct {
 		description      string
+		resource         string
 		request          *pluginapi.AllocateRequest
-		expectedError    error
+		expectError      bool
 		expectedResponse *pluginapi.AllocateResponse
 	}{
 		{
-			description: "single device",
+			description: "multiple container requests are not supported",
+			resource:    "volcano.sh/vgpu-number",
 			request: &pluginapi.AllocateRequest{
 				ContainerRequests: []*pluginapi.ContainerAllocateRequest{
-					{
-						DevicesIds: []string{"foo"},
-					},
-				},
-			},
-			expectedResponse: &pluginapi.AllocateResponse{
-				ContainerResponses: []*pluginapi.ContainerAllocateResponse{
-					{
-						Envs: map[string]string{
-							"NVIDIA_VISIBLE_DEVICES": "foo",
-						},
-					},
+					{DevicesIds: []string{"GPU-0-0"}},
+					{DevicesIds: []string{"GPU-1-0"}},
 				},
 			},
+			expectError:      true,
+			expectedResponse: &pluginapi.AllocateResponse{},
 		},
 		{
-			description: "duplicate device IDs",
+			description: "other vGPU resources get empty responses",
+			resource:    "volcano.sh/vgpu-cores",
 			request: &pluginapi.AllocateRequest{
 				ContainerRequests: []*pluginapi.ContainerAllocateRequest{
-					{
-						DevicesIds: []string{"foo", "bar", "foo"},
-					},
+					{DevicesIds: []string{"GPU-0-core-0", "GPU-0-core-1"}},
 				},
 			},
 			expectedResponse: &pluginapi.AllocateResponse{
-				ContainerResponses: []*pluginapi.ContainerAllocateResponse{
-					{
-						Envs: map[string]string{
-							"NVIDIA_VISIBLE_DEVICES": "foo,bar",
-						},
-					},
+				ContainerResponses: []*pluginapi.ContainerAllocateResponse{{}},
+			},
+		},
+		{
+			description: "devices without a pending pod are not allocated",
+			resource:    "volcano.sh/vgpu-number",
+			request: &pluginapi.AllocateRequest{
+				ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+					{DevicesIds: []string{"GPU-0-0"}},
 				},
 			},
+			expectError:      true,
+			expectedResponse: &pluginapi.AllocateResponse{},
 		},
 	}
 
@@ -80,8 +93,8 @@ func TestAllocate(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			plugin := nvidiaDevicePlugin{
 				rm: &rm.ResourceManagerMock{
-					ValidateRequestFunc: func(annotatedIDs rm.AnnotatedIDs) error {
-						return nil
+					ResourceFunc: func() v1.ResourceName {
+						return v1.ResourceName(tc.resource)
 					},
 				},
 				config: &v1.Config{
@@ -93,16 +106,15 @@ func TestAllocate(t *testing.T) {
 						},
 					},
 				},
-				cdiHandler: &cdi.InterfaceMock{
-					QualifiedNameFunc: func(c string, s string) string {
-						return "nvidia.com/" + c + "=" + s
-					},
-				},
 				deviceListStrategies: v1.DeviceListStrategies{"envvar": true},
 			}
 
 			response, err := plugin.Allocate(context.TODO(), tc.request)
-			require.EqualValues(t, tc.expectedError, err)
+			if tc.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
 			require.EqualValues(t, tc.expectedResponse, response)
 		})
 	}
@@ -239,11 +251,10 @@ func TestCDIAllocateResponse(t *testing.T) {
 				},
 				deviceListStrategies: deviceListStrategies,
 				cdiAnnotationPrefix:  tc.CDIPrefix,
-				imexChannels:         tc.imexChannels,
 			}
 
 			response := pluginapi.ContainerAllocateResponse{}
-			err := plugin.updateResponseForCDI(&response, "uuid", tc.deviceIds...)
+			err := plugin.updateResponseForCDI(&response, "uuid", tc.imexChannels, tc.deviceIds...)
 
 			require.Nil(t, err)
 			require.EqualValues(t, &tc.expectedResponse, &response)
@@ -251,6 +262,64 @@ func TestCDIAllocateResponse(t *testing.T) {
 	}
 }
 
+func TestVGPUCDIAllocateResponse(t *testing.T) {
+	mounts := []*pluginapi.Mount{
+		{ContainerPath: "/usr/local/vgpu/libvgpu.so", HostPath: "/usr/local/vgpu/libvgpu.so", ReadOnly: true},
+		{ContainerPath: "/tmp/vgpu", HostPath: "/usr/local/vgpu/vgpu/containers/uid_main"},
+	}
+	envs := map[string]string{
+		"CUDA_DEVICE_SM_LIMIT":       "30",
+		"CUDA_DEVICE_MEMORY_LIMIT_0": "1000m",
+	}
+	expectedEdits := cdispecs.ContainerEdits{
+		Env: []string{"CUDA_DEVICE_MEMORY_LIMIT_0=1000m", "CUDA_DEVICE_SM_LIMIT=30"},
+		Mounts: []*cdispecs.Mount{
+			{HostPath: "/usr/local/vgpu/libvgpu.so", ContainerPath: "/usr/local/vgpu/libvgpu.so", Options: []string{"ro", "nosuid", "nodev", "bind"}},
+			{HostPath: "/usr/local/vgpu/vgpu/containers/uid_main", ContainerPath: "/tmp/vgpu", Options: []string{"nosuid", "nodev", "bind"}},
+		},
+	}
+
+	for _, strategy := range []string{"cdi-cri", "cdi-annotations"} {
+		t.Run(strategy, func(t *testing.T) {
+			deviceListStrategies, _ := v1.NewDeviceListStrategies([]string{strategy})
+			handler := &cdi.InterfaceMock{
+				QualifiedNameFunc: func(c string, s string) string {
+					return "nvidia.com/" + c + "=" + s
+				},
+				CreateVGPUSpecFileFunc: func(name string, edits cdispecs.ContainerEdits) (string, error) {
+					return "nvidia.com/vgpu=" + name, nil
+				},
+			}
+			plugin := nvidiaDevicePlugin{
+				config:               &v1.Config{},
+				cdiHandler:           handler,
+				deviceListStrategies: deviceListStrategies,
+				cdiAnnotationPrefix:  "cdi.k8s.io/",
+			}
+
+			response := pluginapi.ContainerAllocateResponse{Envs: map[string]string{}}
+			require.NoError(t, plugin.updateResponseForCDI(&response, "uuid", nil, "gpu0"))
+			require.NoError(t, plugin.updateResponseForVGPUCDI(&response, "uid_main", envs, mounts))
+
+			calls := handler.CreateVGPUSpecFileCalls()
+			require.Len(t, calls, 1)
+			require.Equal(t, "uid_main", calls[0].S)
+			require.Equal(t, expectedEdits, calls[0].ContainerEdits)
+			require.Empty(t, response.Envs)
+			require.Empty(t, response.Mounts)
+
+			var devices []string
+			for _, d := range response.CdiDevices {
+				devices = append(devices, d.Name)
+			}
+			for _, v := range response.Annotations {
+				devices = append(devices, v)
+			}
+			require.ElementsMatch(t, []string{"nvidia.com/gpu=gpu0", "nvidia.com/vgpu=uid_main"}, devices)
+		})
+	}
+}
+
 func TestCheckDeviceEntries(t *testing.T) {
 	require.NoError(t, checkDeviceEntries(deviceEntryLimit, 1))
 	// two 80GB cards at factor 1
@@ -261,6 +330,117 @@ func TestCheckDeviceEntries(t *testing.T) {
 	require.ErrorContains(t, checkDeviceEntries(2*deviceEntryLimit, 1), "at least 2")
 }
 
+func TestAPIDevices(t *testing.T) {
+	defer func(name string, count uint) {
+		util.ResourceName, config.DeviceSplitCount = name, count
+	}(util.ResourceName, config.DeviceSplitCount)
+	util.ResourceName = "volcano.sh/vgpu-number"
+	config.DeviceSplitCount = 2
+
+	plugin := nvidiaDevicePlugin{
+		rm: &rm.ResourceManagerMock{
+			ResourceFunc: func() v1.ResourceName {
+				return v1.ResourceName(util.ResourceName)
+			},
+			DevicesFunc: func() rm.Devices {
+				return rm.Devices{
+					"GPU-0": {
+						Device:      pluginapi.Device{ID: "GPU-0", Health: pluginapi.Healthy},
+						TotalMemory: 40960 << 20,
+					},
+					// The memory of a device that fell off the bus can
+					// not be read.
+					"GPU-1": {
+						Device: pluginapi.Device{ID: "GPU-1", Health: pluginapi.Healthy},
+					},
+				}
+			},
+		},
+	}
+
+	health := make(map[string]string)
+	for _, dev := range plugin.apiDevices() {
+		health[dev.ID] = dev.Health
+	}
+	require.Equal(t, map[string]string{
+		"GPU-0-0": pluginapi.Healthy,
+		"GPU-0-1": pluginapi.Healthy,
+		"GPU-1-0": pluginapi.Unhealthy,
+		"GPU-1-1": pluginapi.Unhealthy,
+	}, health)
+}
+
+func TestGetContainerDeviceStrArray(t *testing.T) {
+	defer func(geometries []config.AllowedMigGeometries) {
+		config.SchedulerConfig.MigGeometriesList = geometries
+	}(config.SchedulerConfig.MigGeometriesList)
+	config.SchedulerConfig.MigGeometriesList = []config.AllowedMigGeometries{
+		{
+			Models: []string{"A100-SXM4-40GB"},
+			Geometries: []config.Geometry{
+				{Group: "1g.5gb", Instances: []config.MigTemplate{{Name: "1g.5gb", Memory: 4864, Count: 2}}},
+			},
+		},
+	}
+
+	testCases := []struct {
+		description   string
+		devices       util.ContainerDevices
+		lost          bool
+		expectedIDs   []string
+		expectedError bool
+	}{
+		{
+			description: "GPUs are passed through",
+			devices:     util.ContainerDevices{{UUID: "GPU-0"}, {UUID: "GPU-1"}},
+			lost:        true,
+			expectedIDs: []string{"GPU-0", "GPU-1"},
+		},
+		{
+			description: "MIG devices are looked up",
+			devices:     util.ContainerDevices{{UUID: "GPU-0[1g.5gb-1]"}},
+			expectedIDs: []string{"MIG-1"},
+		},
+		{
+			description:   "lost GPU fails the request",
+			devices:       util.ContainerDevices{{UUID: "GPU-0[1g.5gb-0]"}},
+			lost:          true,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			nvmllib := ct.NewNvml(
+				ct.GPU{UUID: "GPU-0", MemoryMiB: 40960, MigDevices: []ct.MigDevice{
+					{UUID: "MIG-0", MemoryMiB: 4864},
+					{UUID: "MIG-1", MemoryMiB: 4864},
+				}},
+				ct.GPU{UUID: "GPU-1", MemoryMiB: 40960},
+			)
+			if tc.lost {
+				nvmllib.LoseGPU("GPU-0")
+			}
+			plugin := nvidiaDevicePlugin{
+				nvml: nvmllib,
+				migCurrent: config.MigPartedSpec{
+					MigConfigs: map[string]config.MigConfigSpecSlice{
+						"current": {{Devices: []int32{0}, MigEnabled: true, MigDevices: map[string]int32{"1g.5gb": 2}}},
+					},
+				},
+			}
+
+			ids, err := plugin.GetContainerDeviceStrArray(tc.devices)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
 func ptr[T any](x T) *T {
 	return &x
 }
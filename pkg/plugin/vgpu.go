@@ -0,0 +1,223 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	spec "volcano.sh/k8s-device-plugin/api/config/v1"
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	libvgpuName   = "libvgpu.so"
+	ldPreloadName = "ld.so.preload"
+)
+
+// injectionMode is how libvgpu is injected into a container.
+type injectionMode string
+
+const (
+	// injectionFull mounts libvgpu and preloads it, along with the limits.
+	injectionFull injectionMode = "full"
+	// injectionLimitsOnly mounts libvgpu and sets the limits without
+	// preloading it, for applications that dlopen it themselves.
+	injectionLimitsOnly injectionMode = "limits-only"
+	// injectionNone leaves the container unlimited.
+	injectionNone injectionMode = "none"
+)
+
+// Values of util.CoreLimitSwitch understood by libvgpu.
+var coreLimitPolicies = []string{"default", "force", "disable"}
+
+// containerAnnotation returns the value of the annotation of the pod for the
+// container, by prefix followed by the container name, falling back to the
+// annotation of the whole pod.
+func containerAnnotation(pod *corev1.Pod, ctrName, podKey, prefix string) (string, string, bool) {
+	if v, ok := pod.Annotations[prefix+ctrName]; ok {
+		return prefix + ctrName, v, true
+	}
+	if v, ok := pod.Annotations[podKey]; ok {
+		return podKey, v, true
+	}
+	return "", "", false
+}
+
+// injectionModeFor returns the injection mode of a container. Without an
+// annotation it is full, or limits-only when the container sets
+// CUDA_DISABLE_CONTROL.
+func injectionModeFor(pod *corev1.Pod, ctr *corev1.Container) (injectionMode, error) {
+	if key, v, ok := containerAnnotation(pod, ctr.Name, util.InjectionAnnotation, util.InjectionAnnotationPrefix); ok {
+		switch mode := injectionMode(v); mode {
+		case injectionFull, injectionLimitsOnly, injectionNone:
+			return mode, nil
+		}
+		return "", fmt.Errorf("invalid %s %q: must be one of %s, %s or %s", key, v, injectionFull, injectionLimitsOnly, injectionNone)
+	}
+	for _, env := range ctr.Env {
+		if env.Name == "CUDA_DISABLE_CONTROL" {
+			return injectionLimitsOnly, nil
+		}
+	}
+	return injectionFull, nil
+}
+
+// coreLimitPolicyFor returns the util.CoreLimitSwitch of a container, or ""
+// to leave it to libvgpu. Without an annotation it follows
+// config.DisableCoreLimit.
+func coreLimitPolicyFor(pod *corev1.Pod, ctrName string) (string, error) {
+	if key, v, ok := containerAnnotation(pod, ctrName, util.CoreLimitPolicyAnnotation, util.CoreLimitPolicyAnnotationPrefix); ok {
+		for _, policy := range coreLimitPolicies {
+			if v == policy {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("invalid %s %q: must be one of %s", key, v, strings.Join(coreLimitPolicies, ", "))
+	}
+	if config.DisableCoreLimit {
+		return "disable", nil
+	}
+	return "", nil
+}
+
+// taskPriorityFor returns the util.TaskPriority of a container, or "" to
+// leave it to libvgpu. A request for util.ResourcePriority takes precedence
+// over the annotations.
+func taskPriorityFor(pod *corev1.Pod, ctr *corev1.Container) (string, error) {
+	if util.ResourcePriority != "" {
+		if q, ok := ctr.Resources.Limits[corev1.ResourceName(util.ResourcePriority)]; ok {
+			v, ok := q.AsInt64()
+			if !ok || v < 0 || v > math.MaxInt32 {
+				return "", fmt.Errorf("invalid %s %s: must be a non-negative integer", util.ResourcePriority, q.String())
+			}
+			return strconv.FormatInt(v, 10), nil
+		}
+	}
+	if key, v, ok := containerAnnotation(pod, ctr.Name, util.PriorityAnnotation, util.PriorityAnnotationPrefix); ok {
+		p, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || p < 0 {
+			return "", fmt.Errorf("invalid %s %q: must be a non-negative integer", key, v)
+		}
+		return strconv.FormatInt(p, 10), nil
+	}
+	return "", nil
+}
+
+// containerCacheRoot is the host directory holding one cache directory per
+// container, named <pod uid>_<container name>.
+func containerCacheRoot(hookPath string) string {
+	return filepath.Join(hookPath, "vgpu", "containers")
+}
+
+// limitEnvs returns the libvgpu variables limiting the memory and cores of
+// each device of a container to what was reserved on it. The unindexed
+// CUDA_DEVICE_SM_LIMIT is kept for versions of libvgpu limiting every device
+// to the cores of the first one.
+func limitEnvs(devreq util.ContainerDevices) map[string]string {
+	envs := make(map[string]string)
+	for i, dev := range devreq {
+		envs[fmt.Sprintf("CUDA_DEVICE_MEMORY_LIMIT_%v", i)] = fmt.Sprintf("%vm", dev.Usedmem*int32(config.GPUMemoryFactor))
+		envs[fmt.Sprintf("CUDA_DEVICE_SM_LIMIT_%v", i)] = fmt.Sprint(dev.Usedcores)
+	}
+	if len(devreq) > 0 {
+		envs["CUDA_DEVICE_SM_LIMIT"] = fmt.Sprint(devreq[0].Usedcores)
+	}
+	return envs
+}
+
+// memoryPercentageNum is how many util.ResourceMemPercentage devices are
+// advertised per GPU, more than 100 when device memory is oversubscribed.
+func memoryPercentageNum() int {
+	scaling := config.SchedulerConfig.DeviceMemoryScaling
+	if scaling <= 0 {
+		scaling = 1
+	}
+	return int(100 * scaling)
+}
+
+// checkMPSDevices reports whether a container limited as an MPS client can be
+// given the devices the scheduler assigned it. The replicas may sit on several
+// GPUs served by the same daemon, but the memory limits are set by device
+// index, so each GPU can only be assigned once.
+func checkMPSDevices(devreq util.ContainerDevices) error {
+	seen := make(map[string]bool)
+	for _, dev := range devreq {
+		if seen[dev.UUID] {
+			return fmt.Errorf("MPS clients cannot be assigned GPU %s more than once", dev.UUID)
+		}
+		seen[dev.UUID] = true
+	}
+	return nil
+}
+
+// mpsLimitEnvs returns the MPS client variables limiting a container to the
+// memory and cores reserved on each of its devices, as limitEnvs does for
+// libvgpu. MPS takes a single thread percentage per
+// client, so it is the smallest of the devices, 0 cores meaning a device is
+// not limited.
+func mpsLimitEnvs(devreq util.ContainerDevices) map[string]string {
+	envs := make(map[string]string)
+	var limits []string
+	threads := int32(100)
+	for i, dev := range devreq {
+		mem := int64(dev.Usedmem) * int64(config.GPUMemoryFactor)
+		if mem > 0 {
+			limits = append(limits, fmt.Sprintf("%v=%vM", i, mem))
+		}
+		if dev.Usedcores > 0 && dev.Usedcores < threads {
+			threads = dev.Usedcores
+		}
+	}
+	if len(limits) > 0 {
+		envs["CUDA_MPS_PINNED_DEVICE_MEM_LIMIT"] = strings.Join(limits, ",")
+	}
+	if threads < 100 {
+		envs["CUDA_MPS_ACTIVE_THREAD_PERCENTAGE"] = fmt.Sprint(threads)
+	}
+	return envs
+}
+
+// CheckLibvgpu reports whether libvgpu.so is a readable file under the hook
+// path, which it must be for vGPU containers to be limited. It is not needed
+// in mig mode, nor when the containers are limited by MPS.
+func CheckLibvgpu(cfg *spec.Config) error {
+	if config.Mode == "mig" || cfg.Flags.Plugin.VGPUIsolatedByMPS() {
+		return nil
+	}
+	path := filepath.Join(*cfg.Flags.Plugin.HookPath, libvgpuName)
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("libvgpu is not readable: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("libvgpu is not readable: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("libvgpu is not readable: %s is not a regular file", path)
+	}
+	return nil
+}
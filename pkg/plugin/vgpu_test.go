@@ -0,0 +1,391 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	spec "volcano.sh/k8s-device-plugin/api/config/v1"
+	"volcano.sh/k8s-device-plugin/pkg/config"
+	"volcano.sh/k8s-device-plugin/pkg/util"
+)
+
+func TestCheckLibvgpu(t *testing.T) {
+	testCases := []struct {
+		description string
+		mode        string
+		isolation   string
+		setup       func(t *testing.T, hookPath string)
+		expectError bool
+	}{
+		{
+			description: "readable library",
+			mode:        "hami-core",
+			setup: func(t *testing.T, hookPath string) {
+				require.NoError(t, os.WriteFile(filepath.Join(hookPath, libvgpuName), []byte("ELF"), 0644))
+			},
+		},
+		{
+			description: "missing library",
+			mode:        "hami-core",
+			setup:       func(t *testing.T, hookPath string) {},
+			expectError: true,
+		},
+		{
+			description: "directory instead of the library",
+			mode:        "hami-core",
+			setup: func(t *testing.T, hookPath string) {
+				require.NoError(t, os.Mkdir(filepath.Join(hookPath, libvgpuName), 0755))
+			},
+			expectError: true,
+		},
+		{
+			description: "not needed in mig mode",
+			mode:        "mig",
+			setup:       func(t *testing.T, hookPath string) {},
+		},
+		{
+			description: "not needed with MPS isolation",
+			mode:        "hami-core",
+			isolation:   spec.VGPUIsolationMPS,
+			setup:       func(t *testing.T, hookPath string) {},
+		},
+	}
+
+	defer func(mode string) { config.Mode = mode }(config.Mode)
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			hookPath := t.TempDir()
+			tc.setup(t, hookPath)
+			config.Mode = tc.mode
+			cfg := &spec.Config{Flags: spec.Flags{CommandLineFlags: spec.CommandLineFlags{
+				Plugin: &spec.PluginCommandLineFlags{HookPath: &hookPath, VGPUIsolation: &tc.isolation},
+			}}}
+
+			err := CheckLibvgpu(cfg)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestInjectionModeFor(t *testing.T) {
+	testCases := []struct {
+		description string
+		annotations map[string]string
+		env         []corev1.EnvVar
+		expected    injectionMode
+		expectError bool
+	}{
+		{
+			description: "full by default",
+			expected:    injectionFull,
+		},
+		{
+			description: "limits only with CUDA_DISABLE_CONTROL",
+			env:         []corev1.EnvVar{{Name: "CUDA_DISABLE_CONTROL", Value: "true"}},
+			expected:    injectionLimitsOnly,
+		},
+		{
+			description: "pod annotation",
+			annotations: map[string]string{util.InjectionAnnotation: "none"},
+			expected:    injectionNone,
+		},
+		{
+			description: "pod annotation over CUDA_DISABLE_CONTROL",
+			annotations: map[string]string{util.InjectionAnnotation: "full"},
+			env:         []corev1.EnvVar{{Name: "CUDA_DISABLE_CONTROL", Value: "true"}},
+			expected:    injectionFull,
+		},
+		{
+			description: "container annotation over pod annotation",
+			annotations: map[string]string{
+				util.InjectionAnnotation:                "none",
+				util.InjectionAnnotationPrefix + "main": "limits-only",
+				util.InjectionAnnotationPrefix + "side": "full",
+			},
+			expected: injectionLimitsOnly,
+		},
+		{
+			description: "invalid value",
+			annotations: map[string]string{util.InjectionAnnotationPrefix + "main": "partial"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			mode, err := injectionModeFor(pod, &corev1.Container{Name: "main", Env: tc.env})
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, mode)
+		})
+	}
+}
+
+func TestCoreLimitPolicyFor(t *testing.T) {
+	testCases := []struct {
+		description      string
+		annotations      map[string]string
+		disableCoreLimit bool
+		expected         string
+		expectError      bool
+	}{
+		{
+			description: "left to libvgpu by default",
+			expected:    "",
+		},
+		{
+			description:      "disabled on the node",
+			disableCoreLimit: true,
+			expected:         "disable",
+		},
+		{
+			description:      "pod annotation over the node",
+			annotations:      map[string]string{util.CoreLimitPolicyAnnotation: "force"},
+			disableCoreLimit: true,
+			expected:         "force",
+		},
+		{
+			description: "container annotation over pod annotation",
+			annotations: map[string]string{
+				util.CoreLimitPolicyAnnotation:                "force",
+				util.CoreLimitPolicyAnnotationPrefix + "main": "disable",
+			},
+			expected: "disable",
+		},
+		{
+			description: "invalid value",
+			annotations: map[string]string{util.CoreLimitPolicyAnnotation: "off"},
+			expectError: true,
+		},
+	}
+
+	defer func(disable bool) { config.DisableCoreLimit = disable }(config.DisableCoreLimit)
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config.DisableCoreLimit = tc.disableCoreLimit
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			policy, err := coreLimitPolicyFor(pod, "main")
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, policy)
+		})
+	}
+}
+
+func TestTaskPriorityFor(t *testing.T) {
+	testCases := []struct {
+		description string
+		annotations map[string]string
+		limits      corev1.ResourceList
+		expected    string
+		expectError bool
+	}{
+		{
+			description: "left to libvgpu by default",
+			expected:    "",
+		},
+		{
+			description: "pod annotation",
+			annotations: map[string]string{util.PriorityAnnotation: "1"},
+			expected:    "1",
+		},
+		{
+			description: "container annotation over pod annotation",
+			annotations: map[string]string{
+				util.PriorityAnnotation:                "1",
+				util.PriorityAnnotationPrefix + "main": "0",
+			},
+			expected: "0",
+		},
+		{
+			description: "resource over annotations",
+			annotations: map[string]string{util.PriorityAnnotationPrefix + "main": "1"},
+			limits:      corev1.ResourceList{"volcano.sh/vgpu-priority": resource.MustParse("0")},
+			expected:    "0",
+		},
+		{
+			description: "negative annotation",
+			annotations: map[string]string{util.PriorityAnnotation: "-1"},
+			expectError: true,
+		},
+		{
+			description: "non-integer annotation",
+			annotations: map[string]string{util.PriorityAnnotation: "high"},
+			expectError: true,
+		},
+		{
+			description: "fractional resource",
+			limits:      corev1.ResourceList{"volcano.sh/vgpu-priority": resource.MustParse("500m")},
+			expectError: true,
+		},
+	}
+
+	defer func(priority string) { util.ResourcePriority = priority }(util.ResourcePriority)
+	util.ResourcePriority = "volcano.sh/vgpu-priority"
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			ctr := &corev1.Container{Name: "main", Resources: corev1.ResourceRequirements{Limits: tc.limits}}
+			priority, err := taskPriorityFor(pod, ctr)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, priority)
+		})
+	}
+}
+
+func TestLimitEnvs(t *testing.T) {
+	testCases := []struct {
+		description string
+		devreq      util.ContainerDevices
+		expected    map[string]string
+	}{
+		{
+			description: "single device",
+			devreq:      util.ContainerDevices{{UUID: "GPU-0", Usedmem: 1000, Usedcores: 30}},
+			expected: map[string]string{
+				"CUDA_DEVICE_MEMORY_LIMIT_0": "2000m",
+				"CUDA_DEVICE_SM_LIMIT_0":     "30",
+				"CUDA_DEVICE_SM_LIMIT":       "30",
+			},
+		},
+		{
+			description: "devices with different shares",
+			devreq: util.ContainerDevices{
+				{UUID: "GPU-0", Usedmem: 1000, Usedcores: 30},
+				{UUID: "GPU-1", Usedmem: 4000, Usedcores: 80},
+				{UUID: "GPU-2", Usedmem: 500, Usedcores: 0},
+			},
+			expected: map[string]string{
+				"CUDA_DEVICE_MEMORY_LIMIT_0": "2000m",
+				"CUDA_DEVICE_MEMORY_LIMIT_1": "8000m",
+				"CUDA_DEVICE_MEMORY_LIMIT_2": "1000m",
+				"CUDA_DEVICE_SM_LIMIT_0":     "30",
+				"CUDA_DEVICE_SM_LIMIT_1":     "80",
+				"CUDA_DEVICE_SM_LIMIT_2":     "0",
+				"CUDA_DEVICE_SM_LIMIT":       "30",
+			},
+		},
+	}
+
+	defer func(factor uint) { config.GPUMemoryFactor = factor }(config.GPUMemoryFactor)
+	config.GPUMemoryFactor = 2
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, limitEnvs(tc.devreq))
+		})
+	}
+}
+
+func TestCheckMPSDevices(t *testing.T) {
+	testCases := []struct {
+		description string
+		devreq      util.ContainerDevices
+		expectError bool
+	}{
+		{
+			description: "single device",
+			devreq:      util.ContainerDevices{{UUID: "GPU-0", Usedmem: 1000}},
+		},
+		{
+			description: "distinct devices",
+			devreq:      util.ContainerDevices{{UUID: "GPU-0", Usedmem: 1000}, {UUID: "GPU-1", Usedmem: 1000}},
+		},
+		{
+			description: "same device twice",
+			devreq:      util.ContainerDevices{{UUID: "GPU-0", Usedmem: 1000}, {UUID: "GPU-0", Usedmem: 500}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := checkMPSDevices(tc.devreq)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMPSLimitEnvs(t *testing.T) {
+	testCases := []struct {
+		description string
+		devreq      util.ContainerDevices
+		expected    map[string]string
+	}{
+		{
+			description: "single device",
+			devreq:      util.ContainerDevices{{UUID: "GPU-0", Usedmem: 1000, Usedcores: 30}},
+			expected: map[string]string{
+				"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT":  "0=2000M",
+				"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "30",
+			},
+		},
+		{
+			description: "smallest share of cores",
+			devreq: util.ContainerDevices{
+				{UUID: "GPU-0", Usedmem: 1000, Usedcores: 30},
+				{UUID: "GPU-1", Usedmem: 4000, Usedcores: 0},
+				{UUID: "GPU-2", Usedmem: 500, Usedcores: 20},
+			},
+			expected: map[string]string{
+				"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT":  "0=2000M,1=8000M,2=1000M",
+				"CUDA_MPS_ACTIVE_THREAD_PERCENTAGE": "20",
+			},
+		},
+		{
+			description: "cores not limited",
+			devreq:      util.ContainerDevices{{UUID: "GPU-0", Usedmem: 1000, Usedcores: 0}},
+			expected: map[string]string{
+				"CUDA_MPS_PINNED_DEVICE_MEM_LIMIT": "0=2000M",
+			},
+		},
+	}
+
+	defer func(factor uint) { config.GPUMemoryFactor = factor }(config.GPUMemoryFactor)
+	config.GPUMemoryFactor = 2
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, mpsLimitEnvs(tc.devreq))
+		})
+	}
+}
@@ -32,6 +32,9 @@ type Device struct {
 	Index             string
 	TotalMemory       uint64
 	ComputeCapability string
+	// Model is the product name of the device, or of its parent for MIG
+	// devices.
+	Model string
 	// Replicas stores the total number of times this device is replicated.
 	// If this is 0 or 1 then the device is not shared.
 	Replicas int
@@ -44,6 +47,7 @@ type deviceInfo interface {
 	GetNumaNode() (bool, int, error)
 	GetTotalMemory() (uint64, error)
 	GetComputeCapability() (string, error)
+	GetModel() (string, error)
 }
 
 // Devices wraps a map[string]*Device with some functions.
@@ -82,9 +86,15 @@ func BuildDevice(index string, d deviceInfo) (*Device, error) {
 		return nil, fmt.Errorf("error getting device compute capability: %w", err)
 	}
 
+	model, err := d.GetModel()
+	if err != nil {
+		klog.Warningf("Ignoring error getting device model: %v", err)
+	}
+
 	dev := Device{
 		TotalMemory:       totalMemory,
 		ComputeCapability: computeCapability,
+		Model:             model,
 	}
 	dev.ID = uuid
 	dev.Index = index
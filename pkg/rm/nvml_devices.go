@@ -201,3 +201,21 @@ func (d nvmlMigDevice) GetTotalMemory() (uint64, error) {
 	}
 	return info.Total, nil
 }
+
+// GetModel returns the product name of the device.
+func (d nvmlDevice) GetModel() (string, error) {
+	name, ret := d.GetName()
+	if ret != nvml.SUCCESS {
+		return "", ret
+	}
+	return name, nil
+}
+
+// GetModel returns the product name of the parent of the MIG device.
+func (d nvmlMigDevice) GetModel() (string, error) {
+	parent, ret := d.GetDeviceHandleFromMigDeviceHandle()
+	if ret != nvml.SUCCESS {
+		return "", fmt.Errorf("failed to get parent device: %w", ret)
+	}
+	return nvmlDevice{parent}.GetModel()
+}
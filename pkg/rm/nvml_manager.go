@@ -90,8 +90,9 @@ func (r *nvmlResourceManager) GetDevicePaths(ids []string) []string {
 	return append(paths, r.Devices().Subset(ids).GetPaths()...)
 }
 
-// CheckHealth performs health checks on a set of devices, writing to the 'unhealthy' channel with any unhealthy devices
-func (r *nvmlResourceManager) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error {
+// CheckHealth performs health checks on a set of devices, writing to the 'unhealthy' channel with any unhealthy devices.
+// The devices do not recover, so nothing is written to the 'healthy' channel.
+func (r *nvmlResourceManager) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device, healthy chan<- *Device) error {
 	return r.checkHealth(stop, r.devices, unhealthy)
 }
 
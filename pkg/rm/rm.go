@@ -45,7 +45,7 @@ type ResourceManager interface {
 	Devices() Devices
 	GetDevicePaths([]string) []string
 	GetPreferredAllocation(available, required []string, size int) ([]string, error)
-	CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error
+	CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device, healthy chan<- *Device) error
 	ValidateRequest(AnnotatedIDs) error
 }
 
@@ -100,7 +100,12 @@ func (r *resourceManager) ValidateRequest(ids AnnotatedIDs) error {
 func AddDefaultResourcesToConfig(infolib info.Interface, nvmllib nvml.Interface, devicelib device.Interface, config *spec.Config) error {
 	_ = config.Resources.AddGPUResource("*", util.ResourceName)
 	_ = config.Resources.AddGPUResource("*", util.ResourceCores)
-	_ = config.Resources.AddGPUResource("*", util.ResourceMem)
+	// In node-status mode the memory resource is advertised through the node
+	// status rather than by a device plugin.
+	if !config.Flags.Plugin.MemoryInNodeStatus() {
+		_ = config.Resources.AddGPUResource("*", util.ResourceMem)
+	}
+	_ = config.Resources.AddGPUResource("*", util.ResourceMemPercentage)
 	if config.Flags.MigStrategy == nil {
 		return nil
 	}
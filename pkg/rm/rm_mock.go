@@ -19,7 +19,7 @@ var _ ResourceManager = &ResourceManagerMock{}
 //
 //		// make and configure a mocked ResourceManager
 //		mockedResourceManager := &ResourceManagerMock{
-//			CheckHealthFunc: func(stop <-chan interface{}, unhealthy chan<- *Device) error {
+//			CheckHealthFunc: func(stop <-chan interface{}, unhealthy chan<- *Device, healthy chan<- *Device) error {
 //				panic("mock out the CheckHealth method")
 //			},
 //			DevicesFunc: func() Devices {
@@ -45,7 +45,7 @@ var _ ResourceManager = &ResourceManagerMock{}
 //	}
 type ResourceManagerMock struct {
 	// CheckHealthFunc mocks the CheckHealth method.
-	CheckHealthFunc func(stop <-chan interface{}, unhealthy chan<- *Device) error
+	CheckHealthFunc func(stop <-chan interface{}, unhealthy chan<- *Device, healthy chan<- *Device) error
 
 	// DevicesFunc mocks the Devices method.
 	DevicesFunc func() Devices
@@ -70,6 +70,8 @@ type ResourceManagerMock struct {
 			Stop <-chan interface{}
 			// Unhealthy is the unhealthy argument value.
 			Unhealthy chan<- *Device
+			// Healthy is the healthy argument value.
+			Healthy chan<- *Device
 		}
 		// Devices holds details about calls to the Devices method.
 		Devices []struct {
@@ -106,13 +108,15 @@ type ResourceManagerMock struct {
 }
 
 // CheckHealth calls CheckHealthFunc.
-func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error {
+func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device, healthy chan<- *Device) error {
 	callInfo := struct {
 		Stop      <-chan interface{}
 		Unhealthy chan<- *Device
+		Healthy   chan<- *Device
 	}{
 		Stop:      stop,
 		Unhealthy: unhealthy,
+		Healthy:   healthy,
 	}
 	mock.lockCheckHealth.Lock()
 	mock.calls.CheckHealth = append(mock.calls.CheckHealth, callInfo)
@@ -123,7 +127,7 @@ func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy
 		)
 		return errOut
 	}
-	return mock.CheckHealthFunc(stop, unhealthy)
+	return mock.CheckHealthFunc(stop, unhealthy, healthy)
 }
 
 // CheckHealthCalls gets all the calls that were made to CheckHealth.
@@ -133,10 +137,12 @@ func (mock *ResourceManagerMock) CheckHealth(stop <-chan interface{}, unhealthy
 func (mock *ResourceManagerMock) CheckHealthCalls() []struct {
 	Stop      <-chan interface{}
 	Unhealthy chan<- *Device
+	Healthy   chan<- *Device
 } {
 	var calls []struct {
 		Stop      <-chan interface{}
 		Unhealthy chan<- *Device
+		Healthy   chan<- *Device
 	}
 	mock.lockCheckHealth.RLock()
 	calls = mock.calls.CheckHealth
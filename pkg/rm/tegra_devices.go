@@ -17,15 +17,24 @@
 package rm
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	spec "volcano.sh/k8s-device-plugin/api/config/v1"
 )
 
 const (
-	tegraDeviceName = "tegra"
+	tegraDeviceName  = "tegra"
+	tegraDeviceModel = "Tegra"
 )
 
+// procMeminfo is read for the memory of a Tegra device, which shares the
+// memory of the system.
+var procMeminfo = "/proc/meminfo"
+
 // buildTegraDeviceMap creates a DeviceMap for the tegra devices in the sytesm.
 // NOTE: At present only a single tegra device is expected.
 func buildTegraDeviceMap(config *spec.Config) (DeviceMap, error) {
@@ -68,12 +77,39 @@ func (d *tegraDevice) GetNumaNode() (bool, int, error) {
 	return false, -1, nil
 }
 
-// GetTotalMemory is unsupported for a Tegra device.
+// GetTotalMemory returns the total memory of the system, as the memory of a
+// Tegra device is shared with the CPU.
 func (d *tegraDevice) GetTotalMemory() (uint64, error) {
-	return 0, nil
+	f, err := os.Open(procMeminfo)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "MemTotal:" || fields[2] != "kB" {
+			continue
+		}
+		total, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal in %v: %w", procMeminfo, err)
+		}
+		return total * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemTotal in %v", procMeminfo)
 }
 
 // GetComputeCapability is unimplemented for a Tegra device.
 func (d *tegraDevice) GetComputeCapability() (string, error) {
 	return "0.0", nil
 }
+
+// GetModel returns the product name of a Tegra device.
+func (d *tegraDevice) GetModel() (string, error) {
+	return tegraDeviceModel, nil
+}
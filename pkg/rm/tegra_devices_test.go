@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTegraDeviceGetTotalMemory(t *testing.T) {
+	defer func(path string) { procMeminfo = path }(procMeminfo)
+
+	testCases := []struct {
+		description    string
+		meminfo        string
+		expectedMemory uint64
+		expectError    bool
+	}{
+		{
+			description:    "memory of the system",
+			meminfo:        "MemTotal:       31000000 kB\nMemFree:        20000000 kB\n",
+			expectedMemory: 31000000 * 1024,
+		},
+		{
+			description: "no total",
+			meminfo:     "MemFree:        20000000 kB\n",
+			expectError: true,
+		},
+		{
+			description: "invalid total",
+			meminfo:     "MemTotal:       lots kB\n",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			procMeminfo = filepath.Join(t.TempDir(), "meminfo")
+			writeFile(t, procMeminfo, tc.meminfo)
+
+			dev, err := BuildDevice("0", &tegraDevice{})
+			require.NoError(t, err)
+			require.Equal(t, "Tegra", dev.Model)
+
+			memory, err := (&tegraDevice{}).GetTotalMemory()
+			if tc.expectError {
+				require.Error(t, err)
+				require.Zero(t, dev.TotalMemory)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMemory, memory)
+			require.Equal(t, tc.expectedMemory, dev.TotalMemory)
+		})
+	}
+}
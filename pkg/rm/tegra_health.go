@@ -0,0 +1,220 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// envTegraHealthFile defines the environment variable naming a file
+	// written by an external probe of the Tegra GPU. When set, the GPU is
+	// unhealthy while the file reads anything but "ok". A missing file means
+	// the probe has not written it yet.
+	envTegraHealthFile = "DP_TEGRA_HEALTH_FILE"
+
+	tegraSysfsRoot = "/sys"
+)
+
+// tegraHealthCheckInterval is how often the health of Tegra GPUs is checked.
+var tegraHealthCheckInterval = 10 * time.Second
+
+// tegraGPUSuffixes are the suffixes of the names of the platform devices of
+// Tegra GPUs, such as 57000000.gpu or 17000000.ga10b.
+var tegraGPUSuffixes = []string{".gpu", ".gm20b", ".gp10b", ".gv11b", ".ga10b"}
+
+// tegraHealth checks the health of the integrated GPU of a Tegra system from
+// sysfs: the GPU must keep a readable frequency in devfreq, and the counters
+// of uncorrected errors of the nvgpu driver must not grow.
+type tegraHealth struct {
+	// devfreq holds the devfreq directories of the GPUs.
+	devfreq []string
+	// errorCounters holds the last values of the counters of uncorrected
+	// errors, by path.
+	errorCounters map[string]uint64
+	// probeFile is the file written by an external probe, if any.
+	probeFile string
+}
+
+// newTegraHealth finds the GPUs under the sysfs root and the current values
+// of their error counters.
+func newTegraHealth(sysfsRoot string, probeFile string) (*tegraHealth, error) {
+	h := &tegraHealth{
+		errorCounters: make(map[string]uint64),
+		probeFile:     probeFile,
+	}
+
+	entries, err := os.ReadDir(filepath.Join(sysfsRoot, "class", "devfreq"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error listing devfreq devices: %w", err)
+	}
+	for _, entry := range entries {
+		if !isTegraGPUName(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(sysfsRoot, "class", "devfreq", entry.Name())
+		h.devfreq = append(h.devfreq, dir)
+
+		device, err := filepath.EvalSymlinks(filepath.Join(dir, "device"))
+		if err != nil {
+			klog.Warningf("Not checking the error counters of %v: %v", entry.Name(), err)
+			continue
+		}
+		for _, counter := range errorCounterPaths(device) {
+			value, err := readUint(counter)
+			if err != nil {
+				klog.Warningf("Ignoring error counter %v: %v", counter, err)
+				continue
+			}
+			h.errorCounters[counter] = value
+		}
+	}
+	return h, nil
+}
+
+// isTegraGPUName checks whether a platform device name is that of a Tegra GPU.
+func isTegraGPUName(name string) bool {
+	if name == "gpu.0" {
+		return true
+	}
+	for _, suffix := range tegraGPUSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCounterPaths returns the counters of uncorrected errors the nvgpu
+// driver exposes for a GPU, either in its device directory or in its ecc
+// subdirectory. Corrected errors are not counted.
+func errorCounterPaths(device string) []string {
+	var paths []string
+	for _, dir := range []string{device, filepath.Join(device, "ecc")} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, "_count") {
+				continue
+			}
+			if strings.Contains(name, "_ded_") || strings.Contains(name, "uncorrected") || strings.Contains(name, "double_err") {
+				paths = append(paths, filepath.Join(dir, name))
+			}
+		}
+	}
+	return paths
+}
+
+// enabled reports whether there is anything to check.
+func (h *tegraHealth) enabled() bool {
+	return len(h.devfreq) > 0 || h.probeFile != ""
+}
+
+// check returns why the GPU is unhealthy, or nil.
+func (h *tegraHealth) check() error {
+	for _, dir := range h.devfreq {
+		if _, err := readUint(filepath.Join(dir, "cur_freq")); err != nil {
+			return fmt.Errorf("GPU frequency is not readable: %w", err)
+		}
+	}
+	for counter, last := range h.errorCounters {
+		value, err := readUint(counter)
+		if err != nil {
+			return fmt.Errorf("error counter is not readable: %w", err)
+		}
+		if value > last {
+			return fmt.Errorf("%v uncorrected errors in %v", value-last, filepath.Base(counter))
+		}
+	}
+	if h.probeFile != "" {
+		content, err := os.ReadFile(h.probeFile)
+		if errors.Is(err, os.ErrNotExist) {
+			klog.V(4).Infof("Health probe file %v is not written yet", h.probeFile)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("health probe file is not readable: %w", err)
+		}
+		if status := strings.TrimSpace(string(content)); status != "ok" {
+			return fmt.Errorf("health probe reports %q", status)
+		}
+	}
+	return nil
+}
+
+// run checks the health every interval until stop is closed. It sends the
+// devices to unhealthy once the GPU is found unhealthy, and to healthy once it
+// is found healthy again, as the probe may report.
+func (h *tegraHealth) run(stop <-chan interface{}, interval time.Duration, devices Devices, unhealthy, healthy chan<- *Device) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failed := false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		err := h.check()
+		switch {
+		case err != nil && !failed:
+			klog.Infof("Tegra GPU is unhealthy: %v; marking its devices as unhealthy.", err)
+			if !sendDevices(stop, unhealthy, devices) {
+				return
+			}
+			failed = true
+		case err == nil && failed:
+			klog.Info("Tegra GPU is healthy again; marking its devices as healthy.")
+			if !sendDevices(stop, healthy, devices) {
+				return
+			}
+			failed = false
+		}
+	}
+}
+
+// sendDevices sends every device to ch, and reports whether it did before
+// stop was closed.
+func sendDevices(stop <-chan interface{}, ch chan<- *Device, devices Devices) bool {
+	for _, d := range devices {
+		select {
+		case <-stop:
+			return false
+		case ch <- d:
+		}
+	}
+	return true
+}
+
+func readUint(path string) (uint64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2026 The Volcano Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// fakeTegraSysfs creates a sysfs tree with one Tegra GPU under a temporary
+// directory and returns its root and the device directory of the GPU.
+func fakeTegraSysfs(t *testing.T) (string, string) {
+	root := t.TempDir()
+	device := filepath.Join(root, "devices", "platform", "17000000.ga10b")
+	devfreq := filepath.Join(root, "class", "devfreq")
+	require.NoError(t, os.MkdirAll(filepath.Join(device, "ecc"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(devfreq, "17000000.ga10b"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(devfreq, "3d00000.host1x"), 0755))
+	require.NoError(t, os.Symlink(device, filepath.Join(devfreq, "17000000.ga10b", "device")))
+
+	writeFile(t, filepath.Join(devfreq, "17000000.ga10b", "cur_freq"), "1300500000\n")
+	writeFile(t, filepath.Join(device, "ecc", "ltc0_lts0_ecc_sec_count"), "0\n")
+	writeFile(t, filepath.Join(device, "ecc", "ltc0_lts0_ecc_ded_count"), "0\n")
+	writeFile(t, filepath.Join(device, "gpc0_tpc0_sm_uncorrected_err_count"), "2\n")
+	return root, device
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestTegraHealthCheck(t *testing.T) {
+	testCases := []struct {
+		description string
+		probe       string
+		update      func(t *testing.T, root string, device string)
+		expectError bool
+	}{
+		{
+			description: "unchanged GPU is healthy",
+		},
+		{
+			description: "corrected errors are ignored",
+			update: func(t *testing.T, root string, device string) {
+				writeFile(t, filepath.Join(device, "ecc", "ltc0_lts0_ecc_sec_count"), "5\n")
+			},
+		},
+		{
+			description: "double bit errors are unhealthy",
+			update: func(t *testing.T, root string, device string) {
+				writeFile(t, filepath.Join(device, "ecc", "ltc0_lts0_ecc_ded_count"), "1\n")
+			},
+			expectError: true,
+		},
+		{
+			description: "uncorrected errors are unhealthy",
+			update: func(t *testing.T, root string, device string) {
+				writeFile(t, filepath.Join(device, "gpc0_tpc0_sm_uncorrected_err_count"), "3\n")
+			},
+			expectError: true,
+		},
+		{
+			description: "unreadable frequency is unhealthy",
+			update: func(t *testing.T, root string, device string) {
+				require.NoError(t, os.Remove(filepath.Join(root, "class", "devfreq", "17000000.ga10b", "cur_freq")))
+			},
+			expectError: true,
+		},
+		{
+			description: "probe reporting ok is healthy",
+			probe:       "ok\n",
+		},
+		{
+			description: "probe reporting an error is unhealthy",
+			probe:       "GPU has fallen off the bus\n",
+			expectError: true,
+		},
+		{
+			description: "probe file not written yet is healthy",
+			probe:       "ok\n",
+			update: func(t *testing.T, root string, device string) {
+				require.NoError(t, os.Remove(filepath.Join(root, "probe")))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			root, device := fakeTegraSysfs(t)
+			var probeFile string
+			if tc.probe != "" {
+				probeFile = filepath.Join(root, "probe")
+				writeFile(t, probeFile, tc.probe)
+			}
+
+			h, err := newTegraHealth(root, probeFile)
+			require.NoError(t, err)
+			require.True(t, h.enabled())
+			require.Len(t, h.devfreq, 1)
+			require.Len(t, h.errorCounters, 2)
+
+			if tc.update != nil {
+				tc.update(t, root, device)
+			}
+			if tc.expectError {
+				require.Error(t, h.check())
+			} else {
+				require.NoError(t, h.check())
+			}
+		})
+	}
+}
+
+func TestTegraHealthWithoutGPU(t *testing.T) {
+	h, err := newTegraHealth(t.TempDir(), "")
+	require.NoError(t, err)
+	require.False(t, h.enabled())
+}
+
+func TestTegraHealthRun(t *testing.T) {
+	root, _ := fakeTegraSysfs(t)
+	probeFile := filepath.Join(root, "probe")
+	h, err := newTegraHealth(root, probeFile)
+	require.NoError(t, err)
+
+	devices := Devices{
+		"0": &Device{Device: pluginapi.Device{ID: "0"}},
+	}
+	stop := make(chan interface{})
+	unhealthy := make(chan *Device)
+	healthy := make(chan *Device)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.run(stop, time.Millisecond, devices, unhealthy, healthy)
+	}()
+
+	// The probe file is not written yet: the devices are not reported.
+	select {
+	case <-unhealthy:
+		t.Fatal("devices were reported unhealthy before the probe ran")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeFile(t, probeFile, "GPU has fallen off the bus\n")
+	select {
+	case d := <-unhealthy:
+		require.Equal(t, "0", d.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("devices were not reported unhealthy")
+	}
+
+	writeFile(t, probeFile, "ok\n")
+	select {
+	case d := <-healthy:
+		require.Equal(t, "0", d.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("devices were not reported healthy again")
+	}
+
+	close(stop)
+	<-done
+}
@@ -18,6 +18,9 @@ package rm
 
 import (
 	"fmt"
+	"os"
+
+	"k8s.io/klog/v2"
 
 	spec "volcano.sh/k8s-device-plugin/api/config/v1"
 )
@@ -70,7 +73,21 @@ func (r *tegraResourceManager) GetDevicePaths(ids []string) []string {
 	return nil
 }
 
-// CheckHealth is disabled for the tegraResourceManager
-func (r *tegraResourceManager) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device) error {
+// CheckHealth checks the health of the Tegra GPU from sysfs, writing its
+// devices to the 'unhealthy' channel once it is found unhealthy, and to the
+// 'healthy' channel once it recovers.
+func (r *tegraResourceManager) CheckHealth(stop <-chan interface{}, unhealthy chan<- *Device, healthy chan<- *Device) error {
+	if getDisabledHealthCheckXids().IsAllDisabled() {
+		return nil
+	}
+	h, err := newTegraHealth(tegraSysfsRoot, os.Getenv(envTegraHealthFile))
+	if err != nil {
+		return err
+	}
+	if !h.enabled() {
+		klog.Info("No Tegra GPU found in devfreq; continuing with health checks disabled")
+		return nil
+	}
+	h.run(stop, tegraHealthCheckInterval, r.devices, unhealthy, healthy)
 	return nil
 }
@@ -44,3 +44,8 @@ func (d wslDevice) GetTotalMemory() (uint64, error) {
 func (d wslDevice) GetComputeCapability() (string, error) {
 	return nvmlDevice(d).GetComputeCapability()
 }
+
+// GetModel returns the product name of the device.
+func (d wslDevice) GetModel() (string, error) {
+	return nvmlDevice(d).GetModel()
+}
@@ -44,6 +44,13 @@ func GetClient() kubernetes.Interface {
 	return kubeClient
 }
 
+// SetClient replaces the client returned by GetClient, such as with a fake
+// clientset in tests.
+func SetClient(client kubernetes.Interface) {
+	once.Do(func() {})
+	kubeClient = client
+}
+
 // NewClient connects to an API server.
 func NewClient() (kubernetes.Interface, error) {
 	kubeConfig := os.Getenv("KUBECONFIG")
@@ -59,6 +59,34 @@ const (
 	DeviceConfigurationConfigMapKey = "device-config.yaml"
 
 	CoreLimitSwitch = "GPU_CORE_UTILIZATION_POLICY"
+
+	// InjectionAnnotation selects how libvgpu is injected into the containers
+	// of a pod: "full", "limits-only" or "none". InjectionAnnotationPrefix
+	// followed by a container name overrides it for that container.
+	InjectionAnnotation       = "volcano.sh/vgpu-injection"
+	InjectionAnnotationPrefix = "vgpu-injection.volcano.sh/"
+	// CoreLimitPolicyAnnotation sets the CoreLimitSwitch of the containers of
+	// a pod: "default", "force" or "disable". CoreLimitPolicyAnnotationPrefix
+	// followed by a container name overrides it for that container.
+	CoreLimitPolicyAnnotation       = "volcano.sh/vgpu-core-policy"
+	CoreLimitPolicyAnnotationPrefix = "vgpu-core-policy.volcano.sh/"
+
+	// TaskPriority is the priority level libvgpu reports for the container
+	// in its shared region, 0 being the highest.
+	TaskPriority = "CUDA_TASK_PRIORITY"
+	// PriorityAnnotation sets the TaskPriority of the containers of a pod
+	// not requesting ResourcePriority. PriorityAnnotationPrefix followed by a
+	// container name overrides it for that container.
+	PriorityAnnotation       = "volcano.sh/vgpu-priority"
+	PriorityAnnotationPrefix = "vgpu-priority.volcano.sh/"
+
+	// ImexChannelAnnotation asks for an IMEX channel of the pool for the
+	// containers of a pod: "auto" for a free channel, or the ID of a channel
+	// to share with the other pods of a job asking for it. The channel is
+	// recorded in AssignedImexChannelAnnotation.
+	ImexChannelAnnotation         = "volcano.sh/imex-channel"
+	AssignedImexChannelAnnotation = "volcano.sh/imex-channel-assigned"
+	ImexChannelAuto               = "auto"
 )
 
 var (
@@ -25,7 +25,6 @@ import (
 	"math"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -36,8 +35,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
-	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
-	"tags.cncf.io/container-device-interface/specs-go"
 	"volcano.sh/k8s-device-plugin/pkg/config"
 	"volcano.sh/k8s-device-plugin/pkg/util/client"
 	"volcano.sh/k8s-device-plugin/pkg/util/nodelock"
@@ -401,50 +398,8 @@ func LoadConfig(path string) (*config.Config, error) {
 	return &yamlData, nil
 }
 
-func GenerateVirtualDeviceID(id uint, fakeCounter uint) string {
-	return fmt.Sprintf("%d-%d", id, fakeCounter)
-}
-
-// GetDevices returns virtual devices and all physical devices by index.
-func GetDevices(gpuMemoryFactor uint) ([]*pluginapi.Device, map[uint]string) {
-	n, ret := config.Nvml().DeviceGetCount()
-	if ret != nvml.SUCCESS {
-		klog.Fatalf("call nvml.DeviceGetCount with error: %v", ret)
-	}
-
-	var virtualDevs []*pluginapi.Device
-	deviceByIndex := map[uint]string{}
-	for i := uint(0); i < uint(n); i++ {
-		d, ret := config.Nvml().DeviceGetHandleByIndex(int(i))
-		if ret != nvml.SUCCESS {
-			klog.Fatalf("call nvml.DeviceGetHandleByIndex with error: %v", ret)
-		}
-		uuid, ret := d.GetUUID()
-		if ret != nvml.SUCCESS {
-			klog.Fatalf("call GetUUID with error: %v", ret)
-		}
-		id := i
-		deviceByIndex[id] = uuid
-		memory, ret := d.GetMemoryInfo()
-		if ret != nvml.SUCCESS {
-			klog.Fatalf("call GetMemoryInfo with error: %v", ret)
-		}
-		deviceGPUMemory := uint(memory.Total / (1024 * 1024))
-		for j := uint(0); j < deviceGPUMemory/gpuMemoryFactor; j++ {
-			klog.V(4).Infof("adding virtual device: %d", j)
-			fakeID := GenerateVirtualDeviceID(id, j)
-			virtualDevs = append(virtualDevs, &pluginapi.Device{
-				ID:     fakeID,
-				Health: pluginapi.Healthy,
-			})
-		}
-	}
-
-	return virtualDevs, deviceByIndex
-}
-
-func GetDeviceNums() (int, error) {
-	count, ret := config.Nvml().DeviceGetCount()
+func GetDeviceNums(nvmllib nvml.Interface) (int, error) {
+	count, ret := nvmllib.DeviceGetCount()
 	if ret != nvml.SUCCESS {
 		klog.Error(`nvml get count error ret=`, ret)
 		return 0, fmt.Errorf("nvml get count error ret: %s", nvml.ErrorString(ret))
@@ -452,34 +407,34 @@ func GetDeviceNums() (int, error) {
 	return count, nil
 }
 
-func GetIndexAndTypeFromUUID(uuid string) (string, int) {
+// GetIndexAndTypeFromUUID returns the model and index of the GPU of a
+// scheduler UUID, which may carry a MIG template suffix.
+func GetIndexAndTypeFromUUID(nvmllib nvml.Interface, uuid string) (string, int, error) {
 	originuuid := strings.Split(uuid, "[")[0]
-	ndev, ret := config.Nvml().DeviceGetHandleByUUID(originuuid)
+	ndev, ret := nvmllib.DeviceGetHandleByUUID(originuuid)
 	if ret != nvml.SUCCESS {
-		klog.Error("nvml get handlebyuuid error ret=", ret)
-		panic(0)
+		return "", 0, fmt.Errorf("failed to get device %s: %v", originuuid, ret)
 	}
 	model, ret := ndev.GetName()
 	if ret != nvml.SUCCESS {
-		klog.Error("nvml get name error ret=", ret)
-		panic(0)
+		return "", 0, fmt.Errorf("failed to get name of device %s: %v", originuuid, ret)
 	}
 	index, ret := ndev.GetIndex()
 	if ret != nvml.SUCCESS {
-		klog.Error("nvml get index error ret=", ret)
-		panic(0)
+		return "", 0, fmt.Errorf("failed to get index of device %s: %v", originuuid, ret)
 	}
-	return model, index
+	return model, index, nil
 }
 
-func GetMigUUIDFromIndex(uuid string, idx int) string {
+// GetMigUUIDFromIndex returns the UUID of the MIG device at an index of the
+// GPU of a scheduler UUID, asking nvidia-smi when NVML does not know it.
+func GetMigUUIDFromIndex(nvmllib nvml.Interface, uuid string, idx int) (string, error) {
 	originuuid := strings.Split(uuid, "[")[0]
-	ndev, ret := config.Nvml().DeviceGetHandleByUUID(originuuid)
+	ndev, ret := nvmllib.DeviceGetHandleByUUID(originuuid)
 	if ret != nvml.SUCCESS {
-		klog.Error(`nvml get device uuid error ret=`, ret)
-		panic(0)
+		return "", fmt.Errorf("failed to get device %s: %v", originuuid, ret)
 	}
-	migdev, ret := config.Nvml().DeviceGetMigDeviceHandleByIndex(ndev, idx)
+	migdev, ret := nvmllib.DeviceGetMigDeviceHandleByIndex(ndev, idx)
 	if ret != nvml.SUCCESS {
 		klog.Error("nvml get mig dev error ret=", ret, ",idx=", idx, "using nvidia-smi -L for query")
 		cmd := exec.Command("nvidia-smi", "-L")
@@ -488,21 +443,20 @@ func GetMigUUIDFromIndex(uuid string, idx int) string {
 		cmd.Stderr = &stderr
 		err := cmd.Run()
 		if err != nil {
-			klog.Fatalf("nvidia-smi -L failed with %s\n", err)
+			return "", fmt.Errorf("nvidia-smi -L failed: %w: %s", err, stderr.String())
 		}
-		outStr := stdout.String()
-		uuid := GetMigUUIDFromSmiOutput(outStr, originuuid, idx)
-		return uuid
+		return GetMigUUIDFromSmiOutput(stdout.String(), originuuid, idx)
 	}
 	res, ret := migdev.GetUUID()
 	if ret != nvml.SUCCESS {
-		klog.Error(`nvml get mig uuid error ret=`, ret)
-		panic(0)
+		return "", fmt.Errorf("failed to get UUID of MIG device %d of %s: %v", idx, originuuid, ret)
 	}
-	return res
+	return res, nil
 }
 
-func GetMigUUIDFromSmiOutput(output string, uuid string, idx int) string {
+// GetMigUUIDFromSmiOutput returns the UUID of the MIG device at an index of a
+// GPU from the output of nvidia-smi -L.
+func GetMigUUIDFromSmiOutput(output string, uuid string, idx int) (string, error) {
 	migmode := false
 	for _, val := range strings.Split(output, "\n") {
 		if !strings.Contains(val, "MIG") && strings.Contains(val, uuid) {
@@ -517,21 +471,26 @@ func GetMigUUIDFromSmiOutput(output string, uuid string, idx int) string {
 			continue
 		}
 		klog.Infoln("inspecting", val)
-		num := strings.Split(val, "Device")[1]
-		num = strings.Split(num, ":")[0]
-		num = strings.TrimSpace(num)
+		parts := strings.Split(val, "Device")
+		if len(parts) < 2 {
+			return "", fmt.Errorf("unexpected MIG device line %q", val)
+		}
+		num := strings.TrimSpace(strings.Split(parts[1], ":")[0])
 		index, err := strconv.Atoi(num)
 		if err != nil {
-			klog.Fatal("atoi failed num=", num)
+			return "", fmt.Errorf("invalid MIG device index in %q: %w", val, err)
 		}
 		if index == idx {
-			outputStr := strings.Split(val, ":")[2]
-			outputStr = strings.TrimSpace(outputStr)
+			fields := strings.Split(val, ":")
+			if len(fields) < 3 {
+				return "", fmt.Errorf("unexpected MIG device line %q", val)
+			}
+			outputStr := strings.TrimSpace(fields[2])
 			outputStr = strings.TrimRight(outputStr, ")")
-			return outputStr
+			return outputStr, nil
 		}
 	}
-	return ""
+	return "", fmt.Errorf("no MIG device %d of %s in nvidia-smi output", idx, uuid)
 }
 
 // Enhanced ExtractMigTemplatesFromUUID with error handling.
@@ -610,6 +569,7 @@ func LoadNvidiaConfig(c *cli.Context) *config.NvidiaConfig {
 	ResourceMem = nvidiaConfig.ResourceMemoryName
 	ResourceCores = nvidiaConfig.ResourceCoreName
 	ResourceMemPercentage = nvidiaConfig.ResourceMemoryPercentageName
+	ResourcePriority = nvidiaConfig.ResourcePriority
 
 	config.SchedulerConfig = nvidiaConfig
 
@@ -651,69 +611,3 @@ func readFromConfigFile(sConfig *config.NvidiaConfig) error {
 	}
 	return nil
 }
-
-func CheckCDISpecFile(filePath, kind string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("fail to read file: %v", err)
-	}
-	var spec specs.Spec
-	if err := json.Unmarshal(data, &spec); err != nil {
-		return fmt.Errorf("fail to parse json: %v", err)
-	}
-	return checkCDISpec(spec, kind)
-}
-
-func checkCDISpec(spec specs.Spec, kind string) error {
-	if spec.Kind != kind {
-		return fmt.Errorf("kind mismatch. current: %s, expect: %s", spec.Kind, kind)
-	}
-	for _, device := range spec.Devices {
-		if strings.HasPrefix(device.Name, "MIG") {
-			if len(device.ContainerEdits.DeviceNodes) == 0 {
-				return fmt.Errorf("MIG device %s has no deviceNodes", device.Name)
-			}
-			containCap := false
-			for _, node := range device.ContainerEdits.DeviceNodes {
-				if strings.Contains(node.Path, "nvidia-cap") {
-					containCap = true
-					break
-				}
-			}
-			if !containCap {
-				return fmt.Errorf("MIG device %s does not have a corresponding nvidia-cap device", device.Name)
-			}
-		}
-	}
-	return nil
-}
-
-func CreateSpecFile(outputPath string) error {
-	nvidiaCtkPath := "/usrbin/nvidia-ctk"
-	if outputPath == "" {
-		outputPath = "/var/run/cdi/k8s.device-plugin.nvidia.com-gpu.json"
-	}
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %v", outputDir, err)
-	}
-
-	args := []string{
-		"cdi",
-		"generate",
-		"--vendor", "k8s.device-plugin.nvidia.com",
-		"--output", outputPath,
-	}
-
-	cmd := exec.Command(nvidiaCtkPath, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to generate CDI spec file: %v\ncommand: nvidia-ctk %v\noutput: %s",
-			err, args, string(output))
-	}
-
-	if _, err := os.Stat(outputPath); err != nil {
-		return fmt.Errorf("spec file was not created at %s: %v", outputPath, err)
-	}
-	return nil
-}